@@ -0,0 +1,34 @@
+package uggsec
+
+import "context"
+
+// WriteWithAAD behaves like Write but binds the contents to ad
+// instead of the vault's AssociatedData. They can then only be read
+// back by ReadWithAAD with the same ad, anything else fails with
+// ErrTampered. Methods that re-encrypt existing contents, such as
+// Rotate and CopyTo, use the vault's AssociatedData and so can't
+// read contents written with a different ad.
+func (v *Vault) WriteWithAAD(contents string, ad []byte) (err error) {
+	defer wrapOp("write", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	h, b, err := v.wrapPlainText(newHeader(algAES256GCM), []byte(contents))
+	if err != nil {
+		return err
+	}
+	return v.writeBytesHeader(context.Background(), h, b, ad)
+}
+
+// ReadWithAAD behaves like Read but checks the contents against ad
+// instead of the vault's AssociatedData.
+func (v *Vault) ReadWithAAD(ad []byte) (contents string, err error) {
+	defer wrapOp("read", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	data, err := v.readRaw()
+	if err != nil {
+		return "", err
+	}
+	b, err := v.open(context.Background(), string(data), ad)
+	return string(b), err
+}
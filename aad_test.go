@@ -0,0 +1,35 @@
+package uggsec
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWriteReadWithAAD(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.WriteWithAAD("bound to a row", []byte("row 7")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := v.ReadWithAAD([]byte("row 7"))
+	if err != nil || got != "bound to a row" {
+		t.Fatalf("ReadWithAAD() = %q, %v", got, err)
+	}
+}
+
+func TestReadWithOtherAAD(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.WriteWithAAD("bound to a row", []byte("row 7")); err != nil {
+		t.Fatal(err)
+	}
+	for name, ad := range map[string][]byte{
+		"different": []byte("row 8"),
+		"none":      nil,
+	} {
+		if _, err := v.ReadWithAAD(ad); !errors.Is(err, ErrTampered) {
+			t.Errorf("%s: ReadWithAAD() = %v, want ErrTampered", name, err)
+		}
+	}
+	if _, err := v.Read(); !errors.Is(err, ErrTampered) {
+		t.Fatalf("Read() = %v, want ErrTampered", err)
+	}
+}
@@ -0,0 +1,96 @@
+package uggsec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Algorithm names the AEAD a vault's contents are sealed with, see
+// VaultInput.Algorithm
+type Algorithm string
+
+const (
+	// AlgorithmAESGCM is AES in GCM mode, the default. It's the
+	// fastest choice on CPUs with AES instructions.
+	AlgorithmAESGCM Algorithm = "aes-gcm"
+
+	// AlgorithmChaCha20Poly1305 is ChaCha20-Poly1305 with a 256 bit
+	// key, which is faster than AES on CPUs without AES
+	// instructions and constant time everywhere.
+	AlgorithmChaCha20Poly1305 Algorithm = "chacha20poly1305"
+)
+
+// contentKeySize returns the key size in bytes for one of the
+// algorithm ids vault contents are sealed with, or zero if algorithm
+// isn't one of them
+func contentKeySize(algorithm byte) int {
+	switch algorithm {
+	case algAES256GCM, algChaCha20Poly1305:
+		return 32
+	case algAES192GCM:
+		return 24
+	case algAES128GCM:
+		return 16
+	}
+	return 0
+}
+
+// contentAlgorithm returns the algorithm id for sealing contents with
+// the named algorithm and a key of size bytes, zero meaning the
+// default of 32. ChaCha20-Poly1305 only takes 32 byte keys.
+func contentAlgorithm(name Algorithm, size int) (byte, error) {
+	switch name {
+	case "", AlgorithmAESGCM:
+		return gcmAlgorithm(size)
+	case AlgorithmChaCha20Poly1305:
+		if size != 0 && size != chacha20poly1305.KeySize {
+			return 0, fmt.Errorf("%w: KeySize is %d bytes, %s needs %d", ErrInvalidKeyLength, size, name, chacha20poly1305.KeySize)
+		}
+		return algChaCha20Poly1305, nil
+	}
+	return 0, fmt.Errorf("%w: %q, need %q or %q", ErrUnsupportedAlgorithm, name, AlgorithmAESGCM, AlgorithmChaCha20Poly1305)
+}
+
+// gcmAlgorithm returns the GCM algorithm id for an AES key of size
+// bytes, zero meaning the default of 32
+func gcmAlgorithm(size int) (byte, error) {
+	switch size {
+	case 0, 32:
+		return algAES256GCM, nil
+	case 24:
+		return algAES192GCM, nil
+	case 16:
+		return algAES128GCM, nil
+	}
+	return 0, fmt.Errorf("%w: KeySize is %d bytes, need 16, 24 or 32", ErrInvalidKeyLength, size)
+}
+
+// aeadForKey returns the AEAD for algorithm keyed with key. The AEAD
+// keeps its own copy of the key.
+func aeadForKey(algorithm byte, key []byte) (cipher.AEAD, error) {
+	if algorithm == algChaCha20Poly1305 {
+		return chacha20poly1305.New(key)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// withAlgorithm switches a header for sealed contents to the
+// algorithm for the vault's Algorithm and KeySize. Headers for other
+// algorithms, such as streams, are returned unchanged.
+func (v *Vault) withAlgorithm(h header) (header, error) {
+	if contentKeySize(h.algorithm) == 0 {
+		return h, nil
+	}
+	alg, err := contentAlgorithm(v.algorithm, v.keyLen)
+	if err != nil {
+		return h, err
+	}
+	h.algorithm = alg
+	return h, nil
+}
@@ -0,0 +1,76 @@
+package uggsec
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestAlgorithmRoundTrip(t *testing.T) {
+	for _, c := range []struct {
+		name    Algorithm
+		keySize int
+		want    byte
+	}{
+		{"", 0, algAES256GCM},
+		{AlgorithmAESGCM, 16, algAES128GCM},
+		{AlgorithmAESGCM, 24, algAES192GCM},
+		{AlgorithmChaCha20Poly1305, 0, algChaCha20Poly1305},
+	} {
+		v := newTestVault(t, &VaultInput{Algorithm: c.name, KeySize: c.keySize})
+		if err := v.Write("sealed"); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(v.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, _, err := parseHeader(string(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.algorithm != c.want {
+			t.Fatalf("%q with %d byte keys wrote algorithm %02x, want %02x", c.name, c.keySize, h.algorithm, c.want)
+		}
+		// readers pick the AEAD from the header
+		reader := newTestVault(t, &VaultInput{Filename: v.filename})
+		if got, err := reader.Read(); err != nil || got != "sealed" {
+			t.Fatalf("%q: Read() = %q, %v", c.name, got, err)
+		}
+	}
+}
+
+func TestUnknownAlgorithm(t *testing.T) {
+	if _, err := contentAlgorithm("rot13", 0); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Fatalf("contentAlgorithm(rot13) = %v, want ErrUnsupportedAlgorithm", err)
+	}
+	if _, err := contentAlgorithm(AlgorithmChaCha20Poly1305, 16); !errors.Is(err, ErrInvalidKeyLength) {
+		t.Fatalf("16 byte ChaCha20-Poly1305 key = %v, want ErrInvalidKeyLength", err)
+	}
+	if err := Validate(&VaultInput{Filename: "vault", Password: testPassword, Algorithm: "rot13"}); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Fatalf("Validate() = %v, want ErrUnsupportedAlgorithm", err)
+	}
+}
+
+// benchmarkAEAD seals 64KiB with algorithm, leaving out the key
+// derivation that would otherwise dwarf the difference. Run with
+// GODEBUG=cpu.aes=off to compare them as on a CPU without AES
+// instructions.
+func benchmarkAEAD(b *testing.B, algorithm byte) {
+	aead, err := aeadForKey(algorithm, make([]byte, contentKeySize(algorithm)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	plainText := make([]byte, 64<<10)
+	nonce := make([]byte, aead.NonceSize())
+	out := make([]byte, 0, len(plainText)+aead.Overhead())
+	b.SetBytes(int64(len(plainText)))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		out = aead.Seal(out[:0], nonce, plainText, nil)
+	}
+}
+
+func BenchmarkSealAESGCM(b *testing.B) { benchmarkAEAD(b, algAES256GCM) }
+
+func BenchmarkSealChaCha20Poly1305(b *testing.B) { benchmarkAEAD(b, algChaCha20Poly1305) }
@@ -0,0 +1,59 @@
+package uggsec
+
+import (
+	"errors"
+	"os"
+)
+
+var backupSuffix = ".bak"
+
+// replaceVaultFile renames tmpName over the vault's file, first
+// saving the current file as the backup when KeepBackup is set. The
+// temp file is removed if anything fails.
+func (v *Vault) replaceVaultFile(tmpName string) (err error) {
+	v.dropCache()
+	if v.keepBackup {
+		err = v.backupFile()
+		if err != nil {
+			fileBackend.Remove(tmpName)
+			return err
+		}
+	}
+	return replaceFileDurable(tmpName, v.filename, v.fileMode, v.durable)
+}
+
+// backupFile replaces the backup with a copy of the vault's current
+// file. The vault file is copied rather than linked or renamed so
+// that it never goes missing, and so that replaceFile falling back
+// to writing in place can't clobber the backup too. Having no vault
+// file yet is not an error, there's just nothing to back up.
+func (v *Vault) backupFile() error {
+	backupName := v.filename + backupSuffix
+	_, err := fileBackend.Stat(v.filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	v.log("Debug", "backupFile(), backing up vault file...", "file", backupName)
+	f, err := createTempFile(backupName)
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	f.Close()
+	err = copyFile(v.filename, tmpName, v.fileMode)
+	if err != nil {
+		fileBackend.Remove(tmpName)
+		return err
+	}
+	return replaceFileDurable(tmpName, backupName, v.fileMode, v.durable)
+}
+
+// ReadBackup decrypts the previous version of the vault saved by
+// KeepBackup. If there is no backup ErrVaultNotFound is returned.
+func (v *Vault) ReadBackup() (contents string, err error) {
+	defer wrapOp("read backup", v.filename, &err)
+	return v.ReadFile(v.filename + backupSuffix)
+}
@@ -0,0 +1,45 @@
+package uggsec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeepBackup(t *testing.T) {
+	v := newTestVault(t, &VaultInput{KeepBackup: true})
+	if _, err := v.ReadBackup(); !errors.Is(err, ErrVaultNotFound) {
+		t.Fatalf("ReadBackup() with no writes = %v, want ErrVaultNotFound", err)
+	}
+	for _, contents := range []string{"first", "second", "third"} {
+		if err := v.Write(contents); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := v.ReadBackup(); err != nil || got != "second" {
+		t.Fatalf("ReadBackup() = %q, %v, want the previous version", got, err)
+	}
+	if got, err := v.Read(); err != nil || got != "third" {
+		t.Fatalf("Read() = %q, %v", got, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(v.filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("%d files next to the vault, want just it and one backup", len(entries))
+	}
+}
+
+func TestKeepBackupOff(t *testing.T) {
+	v := newTestVault(t, nil)
+	for _, contents := range []string{"first", "second"} {
+		if err := v.Write(contents); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(v.filename + backupSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("backup written without KeepBackup: %v", err)
+	}
+}
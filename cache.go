@@ -0,0 +1,76 @@
+package uggsec
+
+import (
+	"io/fs"
+	"time"
+)
+
+// readCache holds the decrypted contents of a vault along with the
+// modification time and size its file had when they were read
+type readCache struct {
+	modTime  time.Time
+	size     int64
+	contents []byte
+}
+
+// cacheKey returns the modification time and size of the vault's
+// stored ciphertext. In-memory vaults only change through the vault
+// itself so their size is all there is to go on.
+func (v *Vault) cacheKey() (modTime time.Time, size int64, err error) {
+	if v.memory {
+		size, err = v.statRaw()
+		return modTime, size, err
+	}
+	var info fs.FileInfo
+	if v.fsys != nil {
+		info, err = fs.Stat(v.fsys, v.filename)
+	} else {
+		info, err = fileBackend.Stat(v.filename)
+	}
+	if err != nil {
+		return modTime, 0, err
+	}
+	return info.ModTime(), info.Size(), nil
+}
+
+// cachedRead returns a copy of the cached contents if CacheReads is
+// on and the vault's file hasn't changed since they were cached
+func (v *Vault) cachedRead() ([]byte, bool) {
+	if v.cache == nil {
+		return nil, false
+	}
+	modTime, size, err := v.cacheKey()
+	if err != nil || size != v.cache.size || !modTime.Equal(v.cache.modTime) {
+		v.dropCache()
+		return nil, false
+	}
+	v.log("Debug", "cachedRead(), file unchanged, using cached contents")
+	return copyBytes(v.cache.contents), true
+}
+
+// storeCache remembers contents, just read from data, for cachedRead.
+// Contents with an expiry aren't cached since the expiry has to be
+// checked on every read.
+func (v *Vault) storeCache(data, contents []byte) {
+	if !v.cacheReads {
+		return
+	}
+	h, _, err := parseHeader(string(data))
+	if err != nil || h.flags&flagExpiry != 0 {
+		return
+	}
+	modTime, size, err := v.cacheKey()
+	if err != nil || size != int64(len(data)) {
+		return
+	}
+	v.dropCache()
+	v.cache = &readCache{modTime: modTime, size: size, contents: copyBytes(contents)}
+}
+
+// dropCache forgets and wipes any cached contents
+func (v *Vault) dropCache() {
+	if v.cache != nil {
+		wipe(v.cache.contents)
+		v.cache = nil
+	}
+}
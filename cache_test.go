@@ -0,0 +1,83 @@
+package uggsec
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCacheReadsDroppedOnWrite(t *testing.T) {
+	v := newTestVault(t, &VaultInput{CacheReads: true})
+	if err := v.Write("first"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.Read(); err != nil || got != "first" {
+		t.Fatalf("Read() = %q, %v", got, err)
+	}
+	if v.cache == nil {
+		t.Fatal("Read() didn't fill the cache")
+	}
+	if err := v.Write("second"); err != nil {
+		t.Fatal(err)
+	}
+	if v.cache != nil {
+		t.Fatal("Write() didn't drop the cache")
+	}
+	if got, err := v.Read(); err != nil || got != "second" {
+		t.Fatalf("Read() after Write = %q, %v", got, err)
+	}
+}
+
+func TestCacheReadsNoticesChangedFile(t *testing.T) {
+	v := newTestVault(t, &VaultInput{CacheReads: true})
+	other := v.Clone(v.filename)
+	if err := v.Write("first"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Write("changed elsewhere"); err != nil {
+		t.Fatal(err)
+	}
+	// make sure the modification time moves even on coarse clocks
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(v.filename, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.Read(); err != nil || got != "changed elsewhere" {
+		t.Fatalf("Read() = %q, %v, want the other vault's write", got, err)
+	}
+}
+
+func TestCacheReadsSkipsExpiry(t *testing.T) {
+	v := newTestVault(t, &VaultInput{CacheReads: true})
+	if err := v.WriteWithTTL("x", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if v.cache != nil {
+		t.Fatal("contents with an expiry were cached")
+	}
+}
+
+// benchmarkRead reads a vault sealed with the default KDF cost, the
+// cost CacheReads exists to avoid paying on every read
+func benchmarkRead(b *testing.B, cache bool) {
+	v := newTestVault(b, &VaultInput{CacheReads: cache, KDFParams: KDFParams{N: defaultKDFN, R: defaultKDFR, P: defaultKDFP}})
+	if err := v.Write("a small config value"); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := v.Read(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadUncached(b *testing.B) { benchmarkRead(b, false) }
+
+func BenchmarkReadCached(b *testing.B) { benchmarkRead(b, true) }
@@ -0,0 +1,81 @@
+package uggsec
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var checksumSuffix = ".sha256"
+
+// updateChecksum stores the SHA-256 of the vault's file, as it is
+// now on disk, in the sidecar file next to it when WriteChecksum is
+// set. The sidecar uses the format of the sha256sum tool so
+// `sha256sum -c` can check it too. Call it once the vault's file
+// has been replaced.
+func (v *Vault) updateChecksum() error {
+	if !v.writeChecksum {
+		return nil
+	}
+	sum, err := fileSHA256(v.filename)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(v.filename))
+	v.log("Debug", "updateChecksum(), writing checksum...", "file", v.filename+checksumSuffix)
+	return writeFileAtomic(v.filename+checksumSuffix, []byte(line), v.fileMode, v.durable)
+}
+
+// VerifyIntegrity checks the vault's file against the checksum
+// WriteChecksum stored next to it, returning ErrChecksumMismatch if
+// the file was changed by anything other than this package since.
+// No password is needed, which lets monitoring audit vaults it can't
+// read. It only detects changes made without updating the checksum,
+// someone able to write both files can replace both; authentication
+// on read still catches that. A missing checksum file is reported
+// with an error satisfying errors.Is(err, os.ErrNotExist). In-memory
+// and fs.FS vaults return ErrNotSupported.
+func (v *Vault) VerifyIntegrity() (err error) {
+	defer wrapOp("verify integrity", v.filename, &err)
+	if !v.usesOSFiles() {
+		return ErrNotSupported
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	line, err := fileBackend.ReadFile(v.filename + checksumSuffix)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: %s is empty", ErrChecksumMismatch, v.filename+checksumSuffix)
+	}
+	sum, err := fileSHA256(v.filename)
+	if err != nil {
+		return notFoundError(err)
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.ToLower(fields[0])), []byte(sum)) != 1 {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, v.filename)
+	}
+	return nil
+}
+
+// fileSHA256 returns the hex encoded SHA-256 of the file at path
+func fileSHA256(path string) (string, error) {
+	f, err := fileBackend.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,61 @@
+package uggsec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyIntegrityMatches(t *testing.T) {
+	v := newTestVault(t, &VaultInput{WriteChecksum: true})
+	if err := v.Write("audited"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyIntegrity(); err != nil {
+		t.Fatalf("VerifyIntegrity() = %v", err)
+	}
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err := os.ReadFile(v.filename + checksumSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	// the sha256sum format, so the sidecar can be checked by hand
+	want := hex.EncodeToString(sum[:]) + "  " + filepath.Base(v.filename) + "\n"
+	if string(line) != want {
+		t.Fatalf("checksum file holds %q, want %q", line, want)
+	}
+}
+
+func TestVerifyIntegrityMismatch(t *testing.T) {
+	v := newTestVault(t, &VaultInput{WriteChecksum: true})
+	if err := v.Write("audited"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(v.filename, append(data, '\n'), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyIntegrity(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("VerifyIntegrity() = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestVerifyIntegrityMissingChecksum(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("not audited"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyIntegrity(); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("VerifyIntegrity() = %v, want os.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,58 @@
+package uggsec
+
+// Clone returns a new vault that gets its password exactly the way
+// v does, the same keyring label, ENV var or password, and has the
+// same settings, but stores its contents in filename. Nothing is
+// read, written or looked up in the keyring, so Clone is a cheap way
+// to protect several files with one credential. The two vaults don't
+// share any mutable state: each has its own lock and cache, and a
+// Rotate on one doesn't change an explicit password held by the
+// other. Clones of in-memory vaults are new, empty in-memory vaults
+// and ignore filename.
+func (v *Vault) Clone(filename string) *Vault {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c := &Vault{
+		service:        v.service,
+		user:           v.user,
+		filename:       filename,
+		passwordEnvVar: v.passwordEnvVar,
+		envEncoding:    v.envEncoding,
+		keyring:        v.keyring,
+		ring:           v.ring,
+		fileLock:       v.fileLock,
+		lockTimeout:    v.lockTimeout,
+		fileMode:       v.fileMode,
+		password:       copyBytes(v.password),
+		memory:         v.memory,
+		fsys:           v.fsys,
+		logger:         v.logger,
+		deleteExpired:  v.deleteExpired,
+		deterministic:  v.deterministic,
+		compress:       v.compress,
+		keepBackup:     v.keepBackup,
+		associatedData: copyBytes(v.associatedData),
+		observer:       v.observer,
+		secondaryVar:   v.secondaryVar,
+		kdf:            v.kdf,
+		cacheReads:     v.cacheReads,
+		keyLen:         v.keyLen,
+		algorithm:      v.algorithm,
+		rawBinary:      v.rawBinary,
+		encoding:       v.encoding,
+		obfuscate:      v.obfuscate,
+		validateUTF8:   v.validateUTF8,
+		passwordFile:   v.passwordFile,
+		writeChecksum:  v.writeChecksum,
+		readOnly:       v.readOnly,
+		passwordTTL:    v.passwordTTL,
+		randSource:     v.randSource,
+		durable:        v.durable,
+		allowLegacy:    v.allowLegacy,
+		nowFunc:        v.nowFunc,
+	}
+	if c.memory {
+		c.filename = ""
+	}
+	return c
+}
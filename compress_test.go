@@ -0,0 +1,58 @@
+package uggsec
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	contents := strings.Repeat(`{"user":"svc-deploy","scope":"read"},`, 500)
+	sizes := make(map[bool]int)
+	dir := t.TempDir()
+	for _, on := range []bool{false, true} {
+		v := newTestVault(t, &VaultInput{Filename: filepath.Join(dir, "vault"), Compress: on})
+		if err := v.Write(contents); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(v.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, _, err := parseHeader(string(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (h.flags&flagCompressed != 0) != on {
+			t.Fatalf("Compress %v wrote header flags %02x", on, h.flags)
+		}
+		sizes[on] = len(data)
+		// the flag, not the reader's setting, decides how it's read
+		other := v.Clone(v.filename)
+		other.compress = !on
+		if got, err := other.Read(); err != nil || got != contents {
+			t.Fatalf("Compress %v file read with Compress %v: %d bytes, %v", on, !on, len(got), err)
+		}
+	}
+	if sizes[true] >= sizes[false] {
+		t.Fatalf("compressed file is %d bytes, uncompressed %d", sizes[true], sizes[false])
+	}
+}
+
+func TestCompressDecompress(t *testing.T) {
+	for _, b := range [][]byte{nil, []byte("x"), bytes.Repeat([]byte("abc"), 10000)} {
+		c, err := compress(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := decompress(c)
+		if err != nil || !bytes.Equal(got, b) {
+			t.Fatalf("decompress(compress(%d bytes)) = %d bytes, %v", len(b), len(got), err)
+		}
+	}
+	if _, err := decompress([]byte("not deflate data")); err == nil {
+		t.Fatal("decompress() accepted data that isn't deflated")
+	}
+}
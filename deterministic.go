@@ -0,0 +1,82 @@
+package uggsec
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Deterministic mode produces the same ciphertext every time the
+// same contents are written with the same password, which is what
+// callers deduplicating or diffing vault files want. Instead of
+// random values the salt is a fixed public constant, so the key still
+// goes through scrypt with the vault's KDFParams, and the nonce is an
+// HMAC of the plaintext under a key derived from the encryption key
+// (the SIV construction), so different contents still never share a
+// nonce. Nothing computed from the password itself is stored. The
+// payload layout is unchanged which means reading needs no special
+// handling.
+//
+// The fixed salt means one scrypt run tests a guessed password
+// against every deterministic vault at once, rather than against a
+// single file as random salts force. Deterministic vaults deserve
+// strong passwords.
+//
+// The tradeoff is privacy: anyone who can see two deterministic
+// vault files written with the same password can tell whether they
+// hold the same contents, and can tell when a vault is rewritten
+// with contents it held before. Random mode, the default, leaks
+// neither.
+
+// deterministicSalt is the salt deterministic mode derives keys
+// with. It's public and the same for every vault, see above.
+var deterministicSalt = sha256.Sum256([]byte("uggsec deterministic salt v2"))
+
+// encryptDeterministic behaves like encryptHeader but uses a fixed
+// salt and derives the nonce rather than picking them at random
+func encryptDeterministic(h header, plainText, password, ad []byte) (string, error) {
+	salt := append([]byte(nil), deterministicSalt[:saltSize]...)
+	key, err := deriveKey(password, salt, contentKeySize(h.algorithm), h.kdf)
+	if err != nil {
+		return "", err
+	}
+	defer wipe(key)
+	aead, err := aeadForKey(h.algorithm, key)
+	if err != nil {
+		return "", err
+	}
+	nonceKey := hmacSum(key, []byte("uggsec synthetic nonce"))
+	defer wipe(nonceKey)
+	nonce := sivNonce(nonceKey, h.aad(ad), plainText)[:aead.NonceSize()]
+	prefix := append(salt, nonce...)
+	cipherText := aead.Seal(prefix, nonce, plainText, h.aad(ad))
+	return h.String() + h.encodePayload(cipherText), nil
+}
+
+// encryptWith seals b under h with password and the associated data
+// ad in whichever mode the vault was configured for
+func (v *Vault) encryptWith(h header, b, password, ad []byte) (string, error) {
+	if v.deterministic {
+		return encryptDeterministic(h, b, password, ad)
+	}
+	return encryptHeader(v.randReader(), h, b, password, ad)
+}
+
+// sivNonce is the synthetic nonce for sealing plainText with the
+// associated data aad. aad is prefixed with its 8 byte big endian
+// length so that no two different pairs hash the same input, moving
+// bytes from the end of aad to the start of plainText would
+// otherwise give both the same nonce under the same key.
+func sivNonce(nonceKey, aad, plainText []byte) []byte {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(aad)))
+	return hmacSum(nonceKey, n[:], aad, plainText)
+}
+
+func hmacSum(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
@@ -0,0 +1,90 @@
+package uggsec
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// storedPayload returns the decoded payload of the vault file at
+// name, salt and nonce first
+func storedPayload(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, payload, err := parseHeader(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := h.decodePayload(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDeterministicSameContentsSameFile(t *testing.T) {
+	v := newTestVault(t, &VaultInput{Deterministic: true})
+	if err := v.Write("same"); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := os.ReadFile(v.filename)
+	if err := v.Write("same"); err != nil {
+		t.Fatal(err)
+	}
+	second, _ := os.ReadFile(v.filename)
+	if !bytes.Equal(first, second) {
+		t.Fatal("deterministic writes of the same contents differ")
+	}
+	if err := v.Write("different"); err != nil {
+		t.Fatal(err)
+	}
+	third, _ := os.ReadFile(v.filename)
+	if bytes.Equal(first, third) {
+		t.Fatal("different contents produced the same file")
+	}
+	if got, err := v.Read(); err != nil || got != "different" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
+
+func TestDeterministicSaltIsNotFromPassword(t *testing.T) {
+	a := newTestVault(t, &VaultInput{Deterministic: true})
+	b := newTestVault(t, &VaultInput{Deterministic: true, Password: "a different password entirely"})
+	for _, v := range []*Vault{a, b} {
+		if err := v.Write("x"); err != nil {
+			t.Fatal(err)
+		}
+		if salt := storedPayload(t, v.filename)[:saltSize]; !bytes.Equal(salt, deterministicSalt[:saltSize]) {
+			t.Fatalf("stored salt %x isn't the fixed public salt", salt)
+		}
+	}
+}
+
+func TestSIVNonceFramesAssociatedData(t *testing.T) {
+	key := []byte("nonce key")
+	// the same bytes split differently between ad and plaintext
+	a := sivNonce(key, []byte("tenant-12"), []byte("3 secret"))
+	b := sivNonce(key, []byte("tenant-1"), []byte("23 secret"))
+	if bytes.Equal(a, b) {
+		t.Fatal("different (ad, plaintext) pairs share a nonce")
+	}
+}
+
+func TestDeterministicAssociatedDataShiftSealsDifferently(t *testing.T) {
+	dir := t.TempDir()
+	a := newTestVault(t, &VaultInput{Filename: dir + "/a", Deterministic: true, AssociatedData: []byte("ab")})
+	b := newTestVault(t, &VaultInput{Filename: dir + "/b", Deterministic: true, AssociatedData: []byte("a")})
+	if err := a.Write("c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Write("bc"); err != nil {
+		t.Fatal(err)
+	}
+	na, nb := storedPayload(t, a.filename)[saltSize:saltSize+12], storedPayload(t, b.filename)[saltSize:saltSize+12]
+	if bytes.Equal(na, nb) {
+		t.Fatal("shifting bytes between ad and contents reused the nonce")
+	}
+}
@@ -0,0 +1,134 @@
+package uggsec
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Encoding names a text encoding for the ciphertext stored after a
+// vault's header, see VaultInput.Encoding
+type Encoding string
+
+const (
+	// EncodingBase64 is standard base64 with padding, the default
+	EncodingBase64 Encoding = "base64"
+
+	// EncodingHex is lowercase hexadecimal
+	EncodingHex Encoding = "hex"
+)
+
+// PasswordEncoding names how the password in a vault's
+// PasswordEnvVar is written, see VaultInput.PasswordEnvVarEncoding
+type PasswordEncoding string
+
+const (
+	// PasswordEncodingRaw means the ENV var holds the password
+	// itself, the default
+	PasswordEncodingRaw PasswordEncoding = "raw"
+
+	// PasswordEncodingBase64 means the ENV var holds standard
+	// base64 of the password
+	PasswordEncodingBase64 PasswordEncoding = "base64"
+)
+
+// checkPasswordEncoding reports ErrInvalidEncoding unless e is
+// empty or one of the PasswordEncoding constants
+func checkPasswordEncoding(e PasswordEncoding) error {
+	switch e {
+	case "", PasswordEncodingRaw, PasswordEncodingBase64:
+		return nil
+	}
+	return fmt.Errorf("%w: PasswordEnvVarEncoding %q, need %q or %q", ErrInvalidEncoding, e,
+		PasswordEncodingRaw, PasswordEncodingBase64)
+}
+
+// decode turns the contents of the ENV var name into the password
+// they encode. Surrounding whitespace is ignored for base64 since
+// shells and CI systems tend to leave a trailing newline.
+func (e PasswordEncoding) decode(name string, value []byte) ([]byte, error) {
+	if err := checkPasswordEncoding(e); err != nil {
+		return nil, err
+	}
+	if e != PasswordEncodingBase64 {
+		return value, nil
+	}
+	defer wipe(value)
+	password, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(value)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s env var isn't valid base64 but PasswordEnvVarEncoding is %q: %v",
+			ErrInvalidEncoding, name, e, err)
+	}
+	return password, nil
+}
+
+// encode reverses decode, for storing a new password in the ENV var
+func (e PasswordEncoding) encode(password []byte) string {
+	if e == PasswordEncodingBase64 {
+		return base64.StdEncoding.EncodeToString(password)
+	}
+	return string(password)
+}
+
+// checkEncoding reports ErrInvalidEncoding unless e is empty or one
+// of the Encoding constants. A hex encoding can't be combined with
+// RawBinary, which stores no text at all.
+func checkEncoding(e Encoding, rawBinary bool) error {
+	switch e {
+	case "", EncodingBase64:
+		return nil
+	case EncodingHex:
+		if rawBinary {
+			return fmt.Errorf("%w: Encoding %q can't be used with RawBinary", ErrInvalidEncoding, e)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %q, need %q or %q", ErrInvalidEncoding, e, EncodingBase64, EncodingHex)
+}
+
+// withEncoding sets the payload encoding flags on h to match the
+// vault's RawBinary and Encoding settings. Streams are always
+// binary so their headers are left alone.
+func (v *Vault) withEncoding(h header) (header, error) {
+	if h.algorithm == algStreamCTRHMAC || h.algorithm == algStreamGCM {
+		return h, nil
+	}
+	if err := checkEncoding(v.encoding, v.rawBinary); err != nil {
+		return h, err
+	}
+	h.flags &^= flagRawBinary | flagHex
+	switch {
+	case v.rawBinary:
+		h.flags |= flagRawBinary
+	case v.encoding == EncodingHex:
+		h.flags |= flagHex
+	}
+	return h, nil
+}
+
+// encodePayload turns sealed bytes into the payload that follows h
+func (h header) encodePayload(b []byte) string {
+	switch {
+	case h.flags&flagRawBinary != 0:
+		return string(b)
+	case h.flags&flagHex != 0:
+		return hex.EncodeToString(b)
+	}
+	return encode(b)
+}
+
+// decodePayload reverses encodePayload
+func (h header) decodePayload(payload string) ([]byte, error) {
+	switch {
+	case h.flags&flagRawBinary != 0:
+		return []byte(payload), nil
+	case h.flags&flagHex != 0:
+		data, err := hex.DecodeString(payload)
+		if err != nil {
+			return nil, fmt.Errorf("decoding vault contents: %w", err)
+		}
+		return data, nil
+	}
+	return decode(payload)
+}
@@ -0,0 +1,61 @@
+package uggsec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncodingRoundTrip(t *testing.T) {
+	for _, e := range []Encoding{"", EncodingBase64, EncodingHex} {
+		v := newTestVault(t, &VaultInput{Encoding: e})
+		if err := v.Write("encoded either way"); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(v.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, payload, err := parseHeader(string(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (h.flags&flagHex != 0) != (e == EncodingHex) {
+			t.Fatalf("Encoding %q wrote header flags %02x", e, h.flags)
+		}
+		if e == EncodingHex && strings.Trim(payload, "0123456789abcdef") != "" {
+			t.Fatalf("hex vault payload isn't lowercase hex: %q", payload)
+		}
+		// the header, not the reader's setting, decides the decoding
+		reader := v.Clone(v.filename)
+		reader.encoding = ""
+		if got, err := reader.Read(); err != nil || got != "encoded either way" {
+			t.Fatalf("Encoding %q: Read() = %q, %v", e, got, err)
+		}
+	}
+}
+
+func TestInvalidEncoding(t *testing.T) {
+	i := &VaultInput{Filename: filepath.Join(t.TempDir(), "vault"), Encoding: "base32"}
+	v := newTestVault(t, i)
+	if err := v.Write("x"); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("Write() = %v, want ErrInvalidEncoding", err)
+	}
+	if err := Validate(i); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("Validate() = %v, want ErrInvalidEncoding", err)
+	}
+}
+
+func TestPasswordEnvVarEncoding(t *testing.T) {
+	t.Setenv("UGGSEC_TEST_PASSWORD", "Y29ycmVjdCBob3JzZSBiYXR0ZXJ5IHN0YXBsZQ==")
+	password, err := envPassword("UGGSEC_TEST_PASSWORD", PasswordEncodingBase64)
+	if err != nil || string(password) != testPassword {
+		t.Fatalf("envPassword() = %q, %v", password, err)
+	}
+	t.Setenv("UGGSEC_TEST_PASSWORD", "!!! not base64 at all !!!")
+	if _, err := envPassword("UGGSEC_TEST_PASSWORD", PasswordEncodingBase64); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("envPassword() = %v, want ErrInvalidEncoding", err)
+	}
+}
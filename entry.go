@@ -0,0 +1,171 @@
+package uggsec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Entries are named secrets kept in the same encrypted JSON map
+// used by WriteMap and Set, so a vault can act as a small secret
+// store where each secret is written, read and removed on its own.
+
+// WriteEntry stores contents under name, replacing any existing
+// entry with that name and leaving the others alone.
+func (v *Vault) WriteEntry(name, contents string) (err error) {
+	defer wrapOp("write", v.filename, &err)
+	v.log("Debug", "WriteEntry(), writing entry...", "name", name)
+	return v.Set(name, contents)
+}
+
+// ReadEntry returns the contents of the entry called name. If
+// there's no such entry then ErrKeyNotFound is returned.
+func (v *Vault) ReadEntry(name string) (contents string, err error) {
+	defer wrapOp("read", v.filename, &err)
+	return v.Get(name)
+}
+
+// ListEntries returns the names of every entry in the vault in
+// sorted order. Values are never returned.
+func (v *Vault) ListEntries() (names []string, err error) {
+	defer wrapOp("read", v.filename, &err)
+	m, err := v.ReadMap()
+	if err != nil {
+		return nil, err
+	}
+	return sortedNames(m), nil
+}
+
+// Range calls fn for every entry in the vault in sorted name order,
+// stopping early if fn returns false. The vault is decrypted once
+// for the whole iteration rather than once per entry, and the
+// vault's lock isn't held while fn runs so fn may call other methods
+// on the vault, though it won't see their changes. Once Range
+// returns it keeps no reference to the values and the decrypted
+// buffer they were decoded from has been wiped.
+func (v *Vault) Range(fn func(name, value string) bool) (err error) {
+	defer wrapOp("read", v.filename, &err)
+	b, err := v.ReadBytes()
+	if err != nil {
+		return err
+	}
+	m, err := decodeMap(b)
+	wipe(b)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for name := range m {
+			delete(m, name)
+		}
+	}()
+	for _, name := range sortedNames(m) {
+		if !fn(name, m[name]) {
+			break
+		}
+	}
+	return nil
+}
+
+// sortedNames returns the keys of m in sorted order
+func sortedNames(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DeleteEntry removes the entry called name from the vault. If
+// there's no such entry then ErrKeyNotFound is returned and the
+// vault is left untouched.
+func (v *Vault) DeleteEntry(name string) (err error) {
+	defer wrapOp("write", v.filename, &err)
+	v.log("Debug", "DeleteEntry(), deleting entry...", "name", name)
+	return v.updateMap(func(m map[string]string) error {
+		if _, ok := m[name]; !ok {
+			return fmt.Errorf("%w: %q", ErrKeyNotFound, name)
+		}
+		delete(m, name)
+		return nil
+	})
+}
+
+// Compact rewrites a vault holding entries as the smallest file that
+// holds the same entries, like a database vacuum. Entries are stored
+// as one JSON map that's rewritten whole on every change, so deleted
+// entries leave nothing behind, but a map written by WriteBytes or
+// another tool may carry whitespace or keys repeated with an older
+// value, and a file written before Compress or KDFParams were
+// changed keeps its old settings. Compact drops all of that and
+// writes the result atomically like Write, keeping any expiry from
+// WriteWithTTL. When the file is already as small as it can be made
+// and uses the vault's current settings nothing is written, so
+// calling it regularly only costs a read. A vault with no file or an
+// empty one is left alone.
+func (v *Vault) Compact() (err error) {
+	defer wrapOp("compact", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.writable(); err != nil {
+		return err
+	}
+	data, err := v.readRaw()
+	if errors.Is(err, ErrVaultNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	password, err := v.getPassword()
+	if err != nil {
+		return err
+	}
+	defer wipe(password)
+	h, sealed, err := decryptHeader(string(data), password, v.associatedData)
+	if err != nil {
+		return err
+	}
+	defer wipe(sealed)
+	contents, err := unwrapPlainText(h, sealed, v.nowFunc())
+	if err != nil {
+		return err
+	}
+	if h.flags&flagCompressed != 0 {
+		defer wipe(contents)
+	}
+	m, err := decodeMap(contents)
+	if err != nil {
+		return err
+	}
+	var compact []byte
+	if len(m) > 0 {
+		compact, err = json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		defer wipe(compact)
+	}
+	nh, body, err := v.wrapPlainText(newHeader(algAES256GCM), compact)
+	if err != nil {
+		return err
+	}
+	if h.flags&flagExpiry != 0 {
+		// keep the original expiry, it sits in front of everything
+		// else in the sealed plaintext
+		nh.flags |= flagExpiry
+		body = append(append([]byte(nil), sealed[:expirySize]...), body...)
+	}
+	if nh == h && len(compact) == len(contents) {
+		v.log("Debug", "Compact(), vault is already compact")
+		return nil
+	}
+	v.log("Debug", "Compact(), rewriting vault...", "before", len(contents), "after", len(compact))
+	return v.writeBytesHeader(context.Background(), nh, body, v.associatedData)
+}
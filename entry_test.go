@@ -0,0 +1,71 @@
+package uggsec
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// writeEntries stores every entry in m in v
+func writeEntries(t *testing.T, v *Vault, m map[string]string) {
+	t.Helper()
+	for name, contents := range m {
+		if err := v.WriteEntry(name, contents); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEntries(t *testing.T) {
+	v := newTestVault(t, nil)
+	writeEntries(t, v, map[string]string{"db": "hunter2", "api": "token", "smtp": "relay"})
+	names, err := v.ListEntries()
+	if want := []string{"api", "db", "smtp"}; err != nil || !reflect.DeepEqual(names, want) {
+		t.Fatalf("ListEntries() = %q, %v, want %q", names, err, want)
+	}
+	if got, err := v.ReadEntry("db"); err != nil || got != "hunter2" {
+		t.Fatalf("ReadEntry(db) = %q, %v", got, err)
+	}
+	if err := v.DeleteEntry("db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.ReadEntry("db"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("ReadEntry() after DeleteEntry = %v, want ErrKeyNotFound", err)
+	}
+	if err := v.DeleteEntry("db"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("DeleteEntry() of a missing entry = %v, want ErrKeyNotFound", err)
+	}
+	if got, err := v.ReadEntry("api"); err != nil || got != "token" {
+		t.Fatalf("ReadEntry(api) after deleting db = %q, %v", got, err)
+	}
+}
+
+func TestRange(t *testing.T) {
+	v := newTestVault(t, nil)
+	writeEntries(t, v, map[string]string{"b": "2", "a": "1", "c": "3"})
+	var seen []string
+	err := v.Range(func(name, value string) bool {
+		seen = append(seen, name+"="+value)
+		return true
+	})
+	if want := []string{"a=1", "b=2", "c=3"}; err != nil || !reflect.DeepEqual(seen, want) {
+		t.Fatalf("Range() saw %q, %v, want %q", seen, err, want)
+	}
+	seen = nil
+	err = v.Range(func(name, value string) bool {
+		seen = append(seen, name)
+		return name != "b"
+	})
+	if want := []string{"a", "b"}; err != nil || !reflect.DeepEqual(seen, want) {
+		t.Fatalf("Range() stopping at b saw %q, %v", seen, err)
+	}
+	// fn may call back into the vault
+	var inner error
+	err = v.Range(func(name, value string) bool {
+		_, inner = v.ReadEntry(name)
+		return inner == nil
+	})
+	if err != nil || inner != nil {
+		t.Fatalf("Range() reading entries from fn = %v, %v", err, inner)
+	}
+}
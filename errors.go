@@ -0,0 +1,191 @@
+package uggsec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	// ErrTampered is returned when a vault's contents fail authentication
+	// during decryption. This means either the file was modified since it
+	// was written or the password used to read it is not the one that
+	// was used to write it.
+	ErrTampered = errors.New("vault contents failed authentication: file was modified or wrong password")
+
+	// ErrLegacyFormat is returned when a vault written by an older
+	// release of this package is handed to something that needs the
+	// current format, such as Rotate or Decrypt, and by Read unless
+	// VaultInput.AllowLegacy is set. Call Migrate to upgrade it.
+	ErrLegacyFormat = errors.New("vault is in the legacy format, use Migrate to upgrade it")
+
+	// ErrUnsupportedVersion is returned when a vault's header names a
+	// format version (or flags) this release of the package can't
+	// read, typically because it was written by a newer release.
+	ErrUnsupportedVersion = errors.New("unsupported vault format version")
+
+	// ErrUnsupportedAlgorithm is returned when a vault's header, or
+	// VaultInput.Algorithm, names an encryption algorithm this
+	// release of the package doesn't know.
+	ErrUnsupportedAlgorithm = errors.New("unsupported vault encryption algorithm")
+
+	// ErrNoKeyringSecret is returned when the OS keyring works but
+	// holds no password for the vault's Service and User, or only
+	// an empty one. InitKeyring stores a new password in either case.
+	ErrNoKeyringSecret = errors.New("no secret found in keyring")
+
+	// ErrMissingKeyringLabel is returned when a keyring vault is
+	// created without both a Service and a User. Empty labels would
+	// make unrelated programs share, and overwrite, one keyring entry.
+	ErrMissingKeyringLabel = errors.New("keyring vaults need both a Service and a User label")
+
+	// ErrKeyringValueTooLong is returned when the OS keyring refuses
+	// to store a secret because of its size.
+	ErrKeyringValueTooLong = errors.New("secret is too long for the os keyring")
+
+	// ErrKeyringUnavailable is returned when there is no usable OS
+	// keyring at all, such as on a headless Linux box without a
+	// DBus session or secret-service provider. Falling back to an
+	// ENV var is usually the right response.
+	ErrKeyringUnavailable = errors.New("os keyring is unavailable")
+
+	// ErrKeyringLocked is returned when the OS keyring exists but is
+	// locked and couldn't be unlocked without user interaction.
+	ErrKeyringLocked = errors.New("os keyring is locked")
+
+	// ErrKeyringPermission is returned when the OS keyring refused
+	// access, for example because the user denied the prompt.
+	ErrKeyringPermission = errors.New("access to os keyring was denied")
+
+	// ErrKeyringSecretExists is returned by MigrateKeyring when the
+	// label being migrated to already holds a secret.
+	ErrKeyringSecretExists = errors.New("keyring already holds a secret for this label")
+
+	// ErrVaultNotFound is returned when the vault's file does not
+	// exist on disk.
+	ErrVaultNotFound = errors.New("vault file not found")
+
+	// ErrKeyNotFound is returned by Get, ReadEntry and DeleteEntry
+	// when the vault's map has no value stored under the requested
+	// key or entry name.
+	ErrKeyNotFound = errors.New("key not found in vault")
+
+	// ErrExpired is returned when reading a vault written with
+	// WriteWithTTL after its expiry time has passed.
+	ErrExpired = errors.New("vault contents have expired")
+
+	// ErrLockTimeout is returned when UseFileLock is set and the
+	// vault's lock file is still held by someone else once
+	// FileLockTimeout has passed.
+	ErrLockTimeout = errors.New("timed out waiting for vault file lock")
+
+	// ErrNotSupported is returned when a method isn't available for
+	// the kind of storage backing a vault, such as streaming to or
+	// from an in-memory vault.
+	ErrNotSupported = errors.New("operation not supported by this vault")
+
+	// ErrReadOnly is returned when writing to a vault created with
+	// InitFS, whose file system can only be read, or with
+	// VaultInput.ReadOnly set.
+	ErrReadOnly = errors.New("vault is read-only")
+
+	// ErrInvalidUTF8 is returned by Read when ValidateUTF8 is set
+	// and the vault's contents aren't valid UTF-8.
+	ErrInvalidUTF8 = errors.New("vault contents are not valid UTF-8")
+
+	// ErrChecksumMismatch is returned by VerifyIntegrity when the
+	// vault's file doesn't match the checksum stored next to it.
+	ErrChecksumMismatch = errors.New("vault file does not match its checksum")
+
+	// ErrPasswordAgeUnknown is returned by PasswordAge for keyring
+	// passwords set before their rotation time was recorded.
+	ErrPasswordAgeUnknown = errors.New("password age is unknown")
+
+	// ErrMissingPassword is returned when a vault needs a password
+	// but the mechanism it was configured with has none to give.
+	ErrMissingPassword = errors.New("no password provided")
+
+	// ErrWeakPassword is returned when a password was found but is
+	// too short to be accepted.
+	ErrWeakPassword = errors.New("password is too weak")
+
+	// ErrInvalidKeyLength is returned when a key size isn't one AES
+	// accepts, either VaultInput.KeySize or the password of a
+	// legacy vault, which is used as the key directly. The message
+	// includes the actual and expected lengths.
+	ErrInvalidKeyLength = errors.New("invalid encryption key length")
+
+	// ErrInvalidKDFParams is returned when KDFParams, or the
+	// parameters recorded in a vault's header, are outside the
+	// bounds documented on KDFParams.
+	ErrInvalidKDFParams = errors.New("invalid key derivation parameters")
+
+	// ErrInvalidEncoding is returned when VaultInput.Encoding names
+	// an encoding this package doesn't know, or one that conflicts
+	// with RawBinary, and when the password in PasswordEnvVar can't
+	// be decoded as PasswordEnvVarEncoding says.
+	ErrInvalidEncoding = errors.New("invalid vault encoding")
+
+	// ErrInvalidPasswordLength is returned when a password is
+	// requested with a length that isn't positive.
+	ErrInvalidPasswordLength = errors.New("password length must be greater than zero")
+
+	// ErrInvalidAlphabet is returned when a custom password alphabet
+	// is empty, too large or contains the same rune twice.
+	ErrInvalidAlphabet = errors.New("invalid password alphabet")
+
+	// ErrInvalidShares is returned when SplitPassword is given
+	// impossible parameters or CombinePassword can't recover a
+	// password from the shares it was given.
+	ErrInvalidShares = errors.New("invalid password shares")
+)
+
+// OpError is what Vault methods return when they fail, recording
+// the operation and the vault file it was on so that programs
+// juggling several vaults log which one went wrong. It wraps the
+// underlying error, so errors.Is and errors.As see through it to
+// the sentinels above or a *JSONError.
+type OpError struct {
+	// Op is the operation that failed, such as "read", "write" or
+	// "rotate"
+	Op string
+
+	// Filename is the vault file, empty for in-memory vaults
+	Filename string
+
+	Err error
+}
+
+func (e *OpError) Error() string {
+	if e.Filename == "" {
+		return "uggsec: " + e.Op + ": " + e.Err.Error()
+	}
+	return fmt.Sprintf("uggsec: %s %q: %v", e.Op, e.Filename, e.Err)
+}
+
+// Unwrap returns the error the operation failed with
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// wrapOp replaces *err, when it's set, with an OpError for op on
+// filename. Errors that already carry an OpError, from one method
+// calling another, are left alone so the innermost operation is the
+// one reported.
+func wrapOp(op, filename string, err *error) {
+	var oe *OpError
+	if *err == nil || errors.As(*err, &oe) {
+		return
+	}
+	*err = &OpError{Op: op, Filename: filename, Err: *err}
+}
+
+// notFoundError converts file not found errors from the os package
+// into ErrVaultNotFound, keeping the original message for context.
+// Any other error is returned unchanged.
+func notFoundError(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %v", ErrVaultNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+package uggsec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReadFile behaves like Read but decrypts the file at path instead
+// of the vault's own file, using the vault's password. This lets one
+// password, for example a single keyring secret, protect any number
+// of files without creating a Vault for each. Reads are reported to
+// the Observer and AllowLegacy applies, like Read, but the contents
+// are never cached. If path doesn't exist ErrVaultNotFound is
+// returned. In-memory vaults return ErrNotSupported.
+func (v *Vault) ReadFile(path string) (contents string, err error) {
+	defer wrapOp("read", path, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.observer != nil {
+		defer v.observeRead(time.Now(), &err)
+	}
+	err = v.checkPath(path)
+	if err != nil {
+		return "", notFoundError(err)
+	}
+	data, err := fileBackend.ReadFile(path)
+	if err != nil {
+		return "", notFoundError(err)
+	}
+	b, err := v.openStored(context.Background(), data)
+	return string(b), err
+}
+
+// WriteFile behaves like Write but encrypts contents into the file at
+// path instead of the vault's own file, using the vault's password.
+// The write is atomic and takes the lock file next to path when
+// UseFileLock is set, exactly as Write does for the vault's file.
+// In-memory vaults return ErrNotSupported.
+func (v *Vault) WriteFile(path, contents string) (err error) {
+	defer wrapOp("write", path, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.writable(); err != nil {
+		return err
+	}
+	err = v.checkPath(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	encrypted, err := v.seal(context.Background(), []byte(contents))
+	if err != nil {
+		return err
+	}
+	unlock, err := v.lockPath(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	v.log("Debug", "WriteFile(), writing file...", "file", path)
+	return writeFileAtomic(path, []byte(encrypted), v.fileMode, v.durable)
+}
+
+// WriteBatch behaves like calling WriteFile on v for every path and
+// contents in items, but fetches the password only once, which saves
+// a keyring lookup per file. Each file is written atomically and a
+// failure for one doesn't stop the others from being written. If
+// any fail a *BatchError lists them, each as an *OpError naming its
+// file, while failing to get the password at all is returned as is
+// with nothing written.
+func WriteBatch(v *Vault, items map[string]string) (err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.usesOSFiles() {
+		return ErrNotSupported
+	}
+	if err := v.writable(); err != nil {
+		return err
+	}
+	password, err := v.getPassword()
+	if err != nil {
+		return err
+	}
+	defer wipe(password)
+	failed := make(map[string]error)
+	for _, path := range sortedNames(items) {
+		err = v.writeWithPassword(path, []byte(items[path]), password)
+		if err != nil {
+			v.log("Debug", "WriteBatch(), write failed", "file", path, "error", err.Error())
+			failed[path] = err
+		}
+	}
+	if len(failed) > 0 {
+		return &BatchError{Failed: failed}
+	}
+	return nil
+}
+
+// writeWithPassword encrypts contents with password and the vault's
+// settings into the file at path, the way WriteFile does, with
+// failures wrapped in an *OpError for path
+func (v *Vault) writeWithPassword(path string, contents, password []byte) (err error) {
+	defer wrapOp("write", path, &err)
+	err = v.checkPath(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	h, b, err := v.wrapPlainText(newHeader(algAES256GCM), contents)
+	if err != nil {
+		return err
+	}
+	encrypted, err := v.encryptWith(h, b, password, v.associatedData)
+	if err != nil {
+		return err
+	}
+	unlock, err := v.lockPath(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	v.log("Debug", "writeWithPassword(), writing file...", "file", path)
+	return writeFileAtomic(path, []byte(encrypted), v.fileMode, v.durable)
+}
+
+// BatchError is returned by WriteBatch and lists every file that
+// couldn't be written along with the reason.
+type BatchError struct {
+	Failed map[string]error
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for path, err := range e.Failed {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", path, err))
+	}
+	sort.Strings(msgs)
+	return fmt.Sprintf("writing %d of the batch's files failed: %s", len(e.Failed), strings.Join(msgs, "; "))
+}
+
+// Is reports whether any of the failures matches target
+func (e *BatchError) Is(target error) bool {
+	for _, err := range e.Failed {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPath makes sure path can hold a vault for ReadFile and
+// WriteFile. Errors from Stat are returned as is.
+func (v *Vault) checkPath(path string) error {
+	if !v.usesOSFiles() {
+		return ErrNotSupported
+	}
+	if path == "" {
+		return fmt.Errorf("no path given")
+	}
+	info, err := fileBackend.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	return nil
+}
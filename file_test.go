@@ -0,0 +1,158 @@
+package uggsec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// batchItems returns n files to write in dir
+func batchItems(dir string, n int) map[string]string {
+	items := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		items[filepath.Join(dir, "file"+strconv.Itoa(i))] = "contents " + strconv.Itoa(i)
+	}
+	return items
+}
+
+func TestWriteBatchFetchesPasswordOnce(t *testing.T) {
+	calls := 0
+	v := newKeyringTestVault(t, countRing{keyringProvider: mapRing{}, calls: &calls})
+	items := batchItems(t.TempDir(), 5)
+	calls = 0
+	if err := WriteBatch(v, items); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("WriteBatch() made %d keyring calls for %d files, want 1", calls, len(items))
+	}
+	for path, contents := range items {
+		if got, err := v.ReadFile(path); err != nil || got != contents {
+			t.Fatalf("ReadFile(%s) = %q, %v", path, got, err)
+		}
+	}
+}
+
+func TestWriteBatchPartialFailure(t *testing.T) {
+	v := newTestVault(t, nil)
+	dir := t.TempDir()
+	items := batchItems(dir, 3)
+	// a directory can't be written as a file
+	bad := filepath.Join(dir, "subdir")
+	if err := os.Mkdir(bad, 0700); err != nil {
+		t.Fatal(err)
+	}
+	items[bad] = "x"
+	err := WriteBatch(v, items)
+	var batch *BatchError
+	if !errors.As(err, &batch) {
+		t.Fatalf("WriteBatch() = %v, want a *BatchError", err)
+	}
+	if len(batch.Failed) != 1 || batch.Failed[bad] == nil {
+		t.Fatalf("BatchError.Failed = %v, want only %s", batch.Failed, bad)
+	}
+	var oe *OpError
+	if !errors.As(batch.Failed[bad], &oe) || oe.Filename != bad {
+		t.Fatalf("BatchError.Failed[%s] = %v, want an *OpError naming it", bad, batch.Failed[bad])
+	}
+	for path, contents := range items {
+		if path == bad {
+			continue
+		}
+		if got, err := v.ReadFile(path); err != nil || got != contents {
+			t.Fatalf("ReadFile(%s) = %q, %v, want it written despite the failure", path, got, err)
+		}
+	}
+}
+
+// benchmarkKeyringWrites writes 20 files per op to a keyring vault
+// either with WriteBatch or one WriteFile each, reporting how many
+// keyring lookups that took. Each lookup takes 5ms, about what a
+// secret-service round trip over DBus costs.
+func benchmarkKeyringWrites(b *testing.B, batch bool) {
+	calls := 0
+	ring := countRing{keyringProvider: mapRing{}, calls: &calls, delay: 5 * time.Millisecond}
+	old := keyringBackend
+	keyringBackend = ring
+	defer func() { keyringBackend = old }()
+	v, err := InitKeyring(&VaultInput{
+		Filename:  filepath.Join(b.TempDir(), "vault"),
+		Service:   "svc",
+		User:      "usr",
+		KDFParams: KDFParams{N: minKDFN, R: 1, P: 1},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	items := batchItems(b.TempDir(), 20)
+	calls = 0
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if batch {
+			err = WriteBatch(v, items)
+		} else {
+			for _, path := range sortedNames(items) {
+				if err = v.WriteFile(path, items[path]); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(calls)/float64(b.N), "keyring-calls/op")
+}
+
+func BenchmarkWriteBatch(b *testing.B) { benchmarkKeyringWrites(b, true) }
+
+func BenchmarkWriteFileEach(b *testing.B) { benchmarkKeyringWrites(b, false) }
+
+// countObserver counts the reads reported to it
+type countObserver struct{ reads, failed int }
+
+func (o *countObserver) OnRead(dur time.Duration, err error) {
+	o.reads++
+	if err != nil {
+		o.failed++
+	}
+}
+
+func (o *countObserver) OnWrite(time.Duration, error) {}
+
+func (o *countObserver) OnKeyring(string, time.Duration, error) {}
+
+func TestReadFileObserved(t *testing.T) {
+	o := &countObserver{}
+	v := newTestVault(t, &VaultInput{Observer: o})
+	path := filepath.Join(t.TempDir(), "other")
+	if err := v.WriteFile(path, "elsewhere"); err != nil {
+		t.Fatal(err)
+	}
+	// only count the reads below, not the one made by Init
+	*o = countObserver{}
+	if got, err := v.ReadFile(path); err != nil || got != "elsewhere" {
+		t.Fatalf("ReadFile() = %q, %v", got, err)
+	}
+	if _, err := v.ReadFile(path + ".missing"); !errors.Is(err, ErrVaultNotFound) {
+		t.Fatalf("ReadFile(missing) = %v, want ErrVaultNotFound", err)
+	}
+	if o.reads != 2 || o.failed != 1 {
+		t.Fatalf("observer saw %d reads with %d failed, want 2 and 1", o.reads, o.failed)
+	}
+}
+
+func TestReadFileLegacy(t *testing.T) {
+	path := copyLegacyFixture(t)
+	strict := newTestVault(t, &VaultInput{Password: legacyPassword})
+	if _, err := strict.ReadFile(path); !errors.Is(err, ErrLegacyFormat) {
+		t.Fatalf("ReadFile() without AllowLegacy = %v, want ErrLegacyFormat", err)
+	}
+	v := newTestVault(t, &VaultInput{Password: legacyPassword, AllowLegacy: true})
+	if got, err := v.ReadFile(path); err != nil || got != "hello from an old release" {
+		t.Fatalf("ReadFile() = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,124 @@
+package uggsec
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// fileSystem is the set of file operations a vault's file goes
+// through when it's stored on the OS file system. Keeping them behind
+// an interface lets tests fail a rename or cut a write short at an
+// exact point to check that writes stay atomic.
+type fileSystem interface {
+	// WriteFile creates name, which must not exist yet, and writes
+	// data to it with permissions perm
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	Rename(oldName, newName string) error
+
+	// Link creates newName as a hard link to oldName, failing with
+	// an error satisfying errors.Is(err, os.ErrExist) if newName
+	// already exists
+	Link(oldName, newName string) error
+	ReadFile(name string) ([]byte, error)
+	Remove(name string) error
+	Stat(name string) (os.FileInfo, error)
+
+	// Sync flushes name, a file or a directory, to stable storage
+	Sync(name string) error
+
+	// OpenFile opens name like os.OpenFile, for the reads and
+	// writes that go through a file handle rather than a whole file
+	OpenFile(name string, flag int, perm os.FileMode) (file, error)
+}
+
+// file is the part of *os.File that vault code uses on files opened
+// through a fileSystem
+type file interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Closer
+	Name() string
+	Stat() (os.FileInfo, error)
+	Chmod(mode os.FileMode) error
+	Sync() error
+}
+
+// osFileSystem is the fileSystem backed by the os package
+type osFileSystem struct{}
+
+func (osFileSystem) WriteFile(name string, data []byte, perm os.FileMode) (err error) {
+	// O_EXCL so a file or symlink planted at the temp name can't be
+	// written through
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		// perm is subject to the umask when the file is created
+		err = f.Chmod(perm)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (osFileSystem) Rename(oldName, newName string) error {
+	return os.Rename(oldName, newName)
+}
+
+func (osFileSystem) Link(oldName, newName string) error {
+	return os.Link(oldName, newName)
+}
+
+func (osFileSystem) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+func (osFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFileSystem) Sync(name string) (err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (osFileSystem) OpenFile(name string, flag int, perm os.FileMode) (file, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		// a nil *os.File in the interface would compare non-nil
+		return nil, err
+	}
+	return f, nil
+}
+
+// syncDir flushes the directory holding filename so that a file
+// just created or renamed into it survives a power loss. Windows
+// can't sync directories and its filesystems journal renames anyway.
+func syncDir(filename string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	return fileBackend.Sync(filepath.Dir(filename))
+}
+
+// fileBackend is the fileSystem vaults stored in OS files use. Tests
+// swap it out for a fake to inject failures.
+var fileBackend fileSystem = osFileSystem{}
@@ -0,0 +1,133 @@
+package uggsec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var errInjected = errors.New("injected failure")
+
+// faultFS is the OS file system with failures injected on request
+type faultFS struct {
+	osFileSystem
+	failRename bool
+	failLink   bool
+	shortWrite bool
+	failSync   bool
+}
+
+func (f *faultFS) Rename(oldName, newName string) error {
+	if f.failRename {
+		return errInjected
+	}
+	return f.osFileSystem.Rename(oldName, newName)
+}
+
+func (f *faultFS) Link(oldName, newName string) error {
+	if f.failLink {
+		return errInjected
+	}
+	return f.osFileSystem.Link(oldName, newName)
+}
+
+func (f *faultFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if f.shortWrite {
+		f.osFileSystem.WriteFile(name, data[:len(data)/2], perm)
+		return errInjected
+	}
+	return f.osFileSystem.WriteFile(name, data, perm)
+}
+
+func (f *faultFS) Sync(name string) error {
+	if f.failSync {
+		return errInjected
+	}
+	return f.osFileSystem.Sync(name)
+}
+
+// useFileSystem swaps fsys in as the fileBackend for the rest of the
+// test
+func useFileSystem(t *testing.T, fsys fileSystem) {
+	t.Helper()
+	old := fileBackend
+	fileBackend = fsys
+	t.Cleanup(func() { fileBackend = old })
+}
+
+// checkOldContents fails the test unless v still reads as "old" and
+// nothing but the vault file is left in its directory
+func checkOldContents(t *testing.T, v *Vault) {
+	t.Helper()
+	got, err := v.Read()
+	if err != nil || got != "old" {
+		t.Fatalf("Read() = %q, %v, want the old contents", got, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(v.filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != filepath.Base(v.filename) {
+			t.Errorf("%s left behind", e.Name())
+		}
+	}
+}
+
+func TestFailedRenameKeepsOldFile(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("old"); err != nil {
+		t.Fatal(err)
+	}
+	useFileSystem(t, &faultFS{failRename: true})
+	if err := v.Write("new"); !errors.Is(err, errInjected) {
+		t.Fatalf("Write() = %v, want the injected failure", err)
+	}
+	checkOldContents(t, v)
+	if err := v.WriteStream(strings.NewReader("new")); !errors.Is(err, errInjected) {
+		t.Fatalf("WriteStream() = %v, want the injected failure", err)
+	}
+	checkOldContents(t, v)
+}
+
+func TestShortWriteKeepsOldFile(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("old"); err != nil {
+		t.Fatal(err)
+	}
+	useFileSystem(t, &faultFS{shortWrite: true})
+	if err := v.Write("new"); !errors.Is(err, errInjected) {
+		t.Fatalf("Write() = %v, want the injected failure", err)
+	}
+	checkOldContents(t, v)
+}
+
+func TestFailedSyncKeepsOldFile(t *testing.T) {
+	v := newTestVault(t, &VaultInput{Durable: true})
+	if err := v.Write("old"); err != nil {
+		t.Fatal(err)
+	}
+	useFileSystem(t, &faultFS{failSync: true})
+	if err := v.Write("new"); !errors.Is(err, errInjected) {
+		t.Fatalf("Write() = %v, want the injected failure", err)
+	}
+	checkOldContents(t, v)
+}
+
+func TestFaultsClearedWritesAgain(t *testing.T) {
+	v := newTestVault(t, nil)
+	fsys := &faultFS{failRename: true}
+	useFileSystem(t, fsys)
+	if err := v.Write("new"); err == nil {
+		t.Fatal("Write() succeeded with renames failing")
+	}
+	fsys.failRename = false
+	if err := v.Write("new"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.Read(); err != nil || got != "new" {
+		t.Fatalf("Read() = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,270 @@
+package uggsec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Every vault written by this package starts with a short plain text
+// header so readers can tell which format the rest of the file is in
+// and files written by older releases, which had no header at all,
+// can be recognized and migrated.
+//
+// The header is the magic string followed by a two digit hex format
+// version. From version 02 on that is followed by a two digit hex
+// algorithm id and a two digit hex flags byte, which together
+// describe how the payload after the header was produced. When the
+// flags include flagKDFParams three more two digit hex fields follow
+// with the scrypt parameters, see KDFParams. Version 01
+// files carry only magic and version and are always aes-256-gcm.
+// The header is passed to the AEAD as associated data in version 02
+// so that it can't be altered without failing authentication.
+const (
+	formatMagic   = "UGGSEC"
+	formatVersion = 2
+)
+
+// algorithm ids recorded in the header
+const (
+	// algAES256GCM is AES-256-GCM keyed with scrypt, the payload is
+	// the encoded salt, nonce and sealed ciphertext
+	algAES256GCM byte = 0x01
+
+	// algStreamCTRHMAC is what WriteStream used to produce: raw
+	// binary salt, IV, AES-256-CTR ciphertext and an HMAC-SHA256
+	// trailer. It's still read.
+	algStreamCTRHMAC byte = 0x02
+
+	// algX25519Box is what WriteToRecipient produces: an anonymous
+	// NaCl box (X25519, XSalsa20 and Poly1305) sealed to the
+	// recipient's public key, the payload is encoded like GCM's
+	algX25519Box byte = 0x03
+
+	// algAES128GCM and algAES192GCM are algAES256GCM with a shorter
+	// key, see VaultInput.KeySize
+	algAES128GCM byte = 0x04
+	algAES192GCM byte = 0x05
+
+	// algStreamGCM is what WriteStream produces: raw binary salt and
+	// nonce prefix followed by AES-256-GCM sealed chunks, see
+	// stream.go
+	algStreamGCM byte = 0x06
+
+	// algChaCha20Poly1305 is laid out like algAES256GCM but sealed
+	// with ChaCha20-Poly1305, see VaultInput.Algorithm
+	algChaCha20Poly1305 byte = 0x07
+)
+
+// header flag bits
+const (
+	// flagExpiry means the plaintext starts with an 8 byte big
+	// endian expiry time in unix nanoseconds, see WriteWithTTL
+	flagExpiry byte = 0x01
+
+	// flagCompressed means the contents were compressed with
+	// DEFLATE before sealing, see VaultInput.Compress
+	flagCompressed byte = 0x02
+
+	// flagKDFParams means the header carries the scrypt parameters
+	// the key was derived with instead of using the defaults
+	flagKDFParams byte = 0x04
+
+	// flagRawBinary means the payload after the header is the raw
+	// ciphertext rather than base64 of it, see VaultInput.RawBinary
+	flagRawBinary byte = 0x08
+
+	// flagHex means the payload is hex rather than base64 of the
+	// ciphertext, see VaultInput.Encoding
+	flagHex byte = 0x10
+)
+
+// knownFlags holds every flag bit this version of the package knows
+// how to read. Files with any other bit set are rejected rather than
+// misread.
+const knownFlags = flagExpiry | flagCompressed | flagKDFParams | flagRawBinary | flagHex
+
+type header struct {
+	version   int
+	algorithm byte
+	flags     byte
+	kdf       KDFParams
+}
+
+// newHeader returns the header for data written by this version
+// of the package with the given algorithm
+func newHeader(algorithm byte) header {
+	return header{version: formatVersion, algorithm: algorithm}
+}
+
+// String renders the header as it appears at the start of a file
+func (h header) String() string {
+	if h.version == 1 {
+		return formatMagic + "01"
+	}
+	s := fmt.Sprintf("%s%02x%02x%02x", formatMagic, h.version, h.algorithm, h.flags)
+	if h.flags&flagKDFParams != 0 {
+		s += h.kdf.headerString()
+	}
+	return s
+}
+
+// size is the number of bytes String returns
+func (h header) size() int {
+	if h.version == 1 {
+		return len(formatMagic) + 2
+	}
+	if h.flags&flagKDFParams != 0 {
+		return len(formatMagic) + 6 + kdfHeaderSize
+	}
+	return len(formatMagic) + 6
+}
+
+// aad returns the associated data for sealing and opening the
+// payload with an AEAD: the header itself followed by any caller
+// supplied ad
+func (h header) aad(ad []byte) []byte {
+	if h.version == 1 {
+		return ad
+	}
+	return append([]byte(h.String()), ad...)
+}
+
+// wrapPlainText applies the transformations the vault is configured
+// for to contents before they are sealed, recording each in the
+// returned header's flags
+func (v *Vault) wrapPlainText(h header, contents []byte) (header, []byte, error) {
+	h, err := v.applySettings(h)
+	if err != nil {
+		return h, nil, err
+	}
+	if v.compress {
+		b, err := compress(contents)
+		if err != nil {
+			return h, nil, err
+		}
+		h.flags |= flagCompressed
+		contents = b
+	}
+	return h, contents, nil
+}
+
+// applySettings records the vault's KDF parameters, algorithm, key
+// size and payload encoding in h, replacing whatever h had.
+// Rewrites like Rotate use it to move existing contents onto the
+// vault's current settings.
+func (v *Vault) applySettings(h header) (header, error) {
+	h, err := v.withKDF(h)
+	if err != nil {
+		return h, err
+	}
+	h, err = v.withAlgorithm(h)
+	if err != nil {
+		return h, err
+	}
+	return v.withEncoding(h)
+}
+
+// unwrapPlainText strips anything the header's flags say was added
+// to the contents before they were sealed, enforcing the expiry if
+// there is one. Layers are removed in the reverse of the order they
+// were added: the expiry wraps the possibly compressed contents.
+// The expiry is compared against now.
+func unwrapPlainText(h header, plainText []byte, now time.Time) (b []byte, err error) {
+	b = plainText
+	if h.flags&flagExpiry != 0 {
+		b, err = checkExpiry(b, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if h.flags&flagCompressed != 0 {
+		b, err = decompress(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func hasHeader(data string) bool {
+	return strings.HasPrefix(data, formatMagic)
+}
+
+// parseHeader reads the header at the start of data and returns it
+// along with the payload that follows. Data with no header at all is
+// reported as ErrLegacyFormat, headers from a newer release of this
+// package as ErrUnsupportedVersion or ErrUnsupportedAlgorithm.
+func parseHeader(data string) (h header, payload string, err error) {
+	if !hasHeader(data) {
+		return h, "", ErrLegacyFormat
+	}
+	rest := data[len(formatMagic):]
+	version, err := parseHeaderByte(rest, 0)
+	if err != nil {
+		return h, "", err
+	}
+	h.version = int(version)
+	switch h.version {
+	case 1:
+		h.algorithm = algAES256GCM
+	case 2:
+		h.algorithm, err = parseHeaderByte(rest, 1)
+		if err != nil {
+			return h, "", err
+		}
+		h.flags, err = parseHeaderByte(rest, 2)
+		if err != nil {
+			return h, "", err
+		}
+	default:
+		return h, "", fmt.Errorf("%w: %d", ErrUnsupportedVersion, h.version)
+	}
+	if h.flags&^knownFlags != 0 {
+		return h, "", fmt.Errorf("%w: unknown flags %02x", ErrUnsupportedVersion, h.flags&^knownFlags)
+	}
+	if h.flags&flagKDFParams != 0 {
+		h.kdf, err = parseKDFHeader(rest[6:])
+		if err != nil {
+			return h, "", err
+		}
+	}
+	switch h.algorithm {
+	case algAES256GCM, algAES192GCM, algAES128GCM, algStreamCTRHMAC, algX25519Box, algStreamGCM, algChaCha20Poly1305:
+	default:
+		return h, "", fmt.Errorf("%w: id %02x", ErrUnsupportedAlgorithm, h.algorithm)
+	}
+	return h, data[h.size():], nil
+}
+
+// headerExtra returns how many bytes a header whose fixed part is
+// prefix continues for beyond that, for readers that read the
+// header a piece at a time
+func headerExtra(prefix string) int {
+	if !hasHeader(prefix) {
+		return 0
+	}
+	rest := prefix[len(formatMagic):]
+	version, err := parseHeaderByte(rest, 0)
+	if err != nil || version != 2 {
+		return 0
+	}
+	flags, err := parseHeaderByte(rest, 2)
+	if err != nil || flags&flagKDFParams == 0 {
+		return 0
+	}
+	return kdfHeaderSize
+}
+
+// parseHeaderByte decodes the i'th two digit hex field of s
+func parseHeaderByte(s string, i int) (byte, error) {
+	if len(s) < 2*i+2 {
+		return 0, ErrTampered
+	}
+	b, err := strconv.ParseUint(s[2*i:2*i+2], 16, 8)
+	if err != nil {
+		return 0, ErrTampered
+	}
+	return byte(b), nil
+}
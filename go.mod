@@ -2,10 +2,20 @@ module github.com/rendicott/uggsec
 
 go 1.17
 
-require github.com/zalando/go-keyring v0.2.1
+require (
+	github.com/fsnotify/fsnotify v1.5.1
+	github.com/inconshreveable/log15 v0.0.0-20201112154412-8562bdadbbac
+	github.com/zalando/go-keyring v0.2.1
+	golang.org/x/crypto v0.0.0-20220214200702-86341886e292
+	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
+)
 
 require (
 	github.com/alessio/shellescape v1.4.1 // indirect
 	github.com/danieljoos/wincred v1.1.0 // indirect
+	github.com/go-stack/stack v1.8.1 // indirect
 	github.com/godbus/dbus/v5 v5.0.6 // indirect
+	github.com/mattn/go-colorable v0.1.12 // indirect
+	github.com/mattn/go-isatty v0.0.14 // indirect
+	golang.org/x/sys v0.0.0-20210927094055-39ccf1dd6fa6 // indirect
 )
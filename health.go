@@ -0,0 +1,77 @@
+package uggsec
+
+import (
+	"context"
+	"errors"
+)
+
+// HealthError is returned by HealthCheck and says which part of the
+// check failed, so monitoring can tell an unavailable keyring apart
+// from a corrupt vault file. Err is whatever the failing step
+// returned, such as ErrKeyringUnavailable or ErrTampered.
+type HealthError struct {
+	// Check is "password" when the password couldn't be retrieved
+	// and "contents" when the vault couldn't be read or decrypted
+	Check string
+	Err   error
+}
+
+func (e *HealthError) Error() string {
+	return "vault health check failed on " + e.Check + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error from the failing step
+func (e *HealthError) Unwrap() error {
+	return e.Err
+}
+
+// HealthCheck confirms the vault is usable, for a service's health
+// endpoint: the password has to be retrievable and the stored
+// contents have to decrypt. Nothing is written, cached or deleted,
+// even expired contents are left in place and reported as
+// ErrExpired. A vault with no file fails with ErrVaultNotFound, one
+// with an empty file passes. Failures are returned as *HealthError.
+// The whole file is authenticated, which for large WriteStream
+// vaults means reading all of it.
+func (v *Vault) HealthCheck(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.log("Debug", "HealthCheck(), getting password...")
+	password, err := v.getPasswordContext(ctx)
+	if err != nil {
+		return &HealthError{Check: "password", Err: err}
+	}
+	wipe(password)
+	v.log("Debug", "HealthCheck(), decrypting contents...")
+	if err := v.checkContents(ctx); err != nil {
+		return &HealthError{Check: "contents", Err: err}
+	}
+	return nil
+}
+
+// checkContents decrypts the vault's stored contents and throws
+// them away, bypassing the read cache and observer
+func (v *Vault) checkContents(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if v.usesOSFiles() {
+		rc, err := v.openStream()
+		if err == nil {
+			return rc.Close()
+		}
+		if err != errNotStream && !errors.Is(err, ErrLegacyFormat) {
+			return err
+		}
+	}
+	data, err := v.readRaw()
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	contents, err := v.openStored(ctx, data)
+	wipe(contents)
+	return err
+}
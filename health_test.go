@@ -0,0 +1,70 @@
+package uggsec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+// checkHealthError fails the test unless err is a *HealthError for
+// check wrapping target
+func checkHealthError(t *testing.T, err error, check string, target error) {
+	t.Helper()
+	var he *HealthError
+	if !errors.As(err, &he) || he.Check != check || !errors.Is(err, target) {
+		t.Fatalf("HealthCheck() = %v, want a %q HealthError wrapping %v", err, check, target)
+	}
+}
+
+func TestHealthCheckHealthy(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("fine"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("HealthCheck() after Write = %v", err)
+	}
+	if err := v.WriteStream(bytes.NewReader(streamPayload(streamChunkSize + 1))); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("HealthCheck() after WriteStream = %v", err)
+	}
+}
+
+func TestHealthCheckMissingFile(t *testing.T) {
+	v := newTestVault(t, nil)
+	checkHealthError(t, v.HealthCheck(context.Background()), "contents", ErrVaultNotFound)
+}
+
+func TestHealthCheckWrongPassword(t *testing.T) {
+	v := newTestVault(t, nil)
+	other := newTestVault(t, &VaultInput{Filename: v.filename, Password: "a different password entirely"})
+	if err := v.Write("fine"); err != nil {
+		t.Fatal(err)
+	}
+	checkHealthError(t, other.HealthCheck(context.Background()), "contents", ErrTampered)
+}
+
+func TestHealthCheckPasswordUnavailable(t *testing.T) {
+	t.Setenv("UGGSEC_HEALTH_TEST", testPassword)
+	v, err := InitEnvVar(&VaultInput{Filename: t.TempDir() + "/vault", PasswordEnvVar: "UGGSEC_HEALTH_TEST",
+		KDFParams: KDFParams{N: minKDFN, R: 1, P: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Unsetenv("UGGSEC_HEALTH_TEST")
+	checkHealthError(t, v.HealthCheck(context.Background()), "password", ErrMissingPassword)
+}
+
+func TestHealthCheckLegacy(t *testing.T) {
+	v, err := InitPassword(&VaultInput{Filename: copyLegacyFixture(t), Password: legacyPassword, AllowLegacy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("HealthCheck() of a legacy vault = %v", err)
+	}
+}
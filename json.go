@@ -0,0 +1,57 @@
+package uggsec
+
+import (
+	"encoding/json"
+)
+
+// JSONError is returned by WriteJSON and ReadJSON when the value
+// itself couldn't be encoded or the decrypted contents couldn't be
+// decoded, as opposed to the vault failing to be read or written.
+// Use errors.As to tell the two apart.
+type JSONError struct {
+	// Op is "encoding" or "decoding"
+	Op  string
+	Err error
+}
+
+func (e *JSONError) Error() string {
+	return e.Op + " vault contents as JSON: " + e.Err.Error()
+}
+
+// Unwrap returns the error from encoding/json
+func (e *JSONError) Unwrap() error {
+	return e.Err
+}
+
+// WriteJSON JSON encodes value and writes it as the vault's
+// contents, replacing anything previously stored. It's meant for
+// keeping something like a config struct of secrets in one vault.
+func (v *Vault) WriteJSON(value interface{}) (err error) {
+	defer wrapOp("write", v.filename, &err)
+	b, err := json.Marshal(value)
+	if err != nil {
+		return &JSONError{Op: "encoding", Err: err}
+	}
+	defer wipe(b)
+	return v.WriteBytes(b)
+}
+
+// ReadJSON decrypts the vault's contents and decodes them into the
+// value pointed to by into, following the rules of json.Unmarshal.
+// An empty vault leaves into untouched.
+func (v *Vault) ReadJSON(into interface{}) (err error) {
+	defer wrapOp("read", v.filename, &err)
+	b, err := v.ReadBytes()
+	if err != nil {
+		return err
+	}
+	defer wipe(b)
+	if len(b) == 0 {
+		return nil
+	}
+	err = json.Unmarshal(b, into)
+	if err != nil {
+		return &JSONError{Op: "decoding", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package uggsec
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type jsonTestConfig struct {
+	User  string   `json:"user"`
+	Port  int      `json:"port"`
+	Hosts []string `json:"hosts"`
+}
+
+func TestWriteReadJSON(t *testing.T) {
+	v := newTestVault(t, nil)
+	want := jsonTestConfig{User: "svc", Port: 5432, Hosts: []string{"a", "b"}}
+	if err := v.WriteJSON(want); err != nil {
+		t.Fatal(err)
+	}
+	var got jsonTestConfig
+	if err := v.ReadJSON(&got); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadJSON() = %+v, %v, want %+v", got, err, want)
+	}
+}
+
+func TestReadJSONDecodeError(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("not json"); err != nil {
+		t.Fatal(err)
+	}
+	var got jsonTestConfig
+	err := v.ReadJSON(&got)
+	var je *JSONError
+	if !errors.As(err, &je) || je.Op != "decoding" {
+		t.Fatalf("ReadJSON() = %v, want a decoding *JSONError", err)
+	}
+	// a value of the wrong type is a decoding failure too
+	if err := v.WriteJSON(map[string]string{"port": "not a number"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.ReadJSON(&got); !errors.As(err, &je) {
+		t.Fatalf("ReadJSON() = %v, want a *JSONError", err)
+	}
+}
+
+func TestWriteJSONEncodeError(t *testing.T) {
+	v := newTestVault(t, nil)
+	err := v.WriteJSON(func() {})
+	var je *JSONError
+	if !errors.As(err, &je) || je.Op != "encoding" {
+		t.Fatalf("WriteJSON() = %v, want an encoding *JSONError", err)
+	}
+	var got jsonTestConfig
+	if err := v.ReadJSON(&got); !errors.Is(err, ErrVaultNotFound) {
+		t.Fatalf("ReadJSON() after a failed WriteJSON = %v, want ErrVaultNotFound", err)
+	}
+}
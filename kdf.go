@@ -0,0 +1,119 @@
+package uggsec
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// KDFParams sets the cost of the scrypt key derivation every read
+// and write goes through. Each derivation needs about 128*N*R bytes
+// of memory and time roughly proportional to N*R*P, so doubling N
+// doubles both the work an attacker has to do per guessed password
+// and the latency of every vault operation. The defaults, N=32768
+// R=8 P=1, take 32 MiB and tens of milliseconds, a reasonable
+// choice for interactive use. Background services that read rarely
+// can afford more, anything reading in a tight loop should cache
+// the contents rather than lower the cost.
+//
+// Zero fields take their default. The parameters a vault was
+// written with are recorded in its header, so readers always use
+// the right ones whatever their own VaultInput says.
+type KDFParams struct {
+	// N is the CPU and memory cost, a power of two between
+	// 16384 and 1048576
+	N int
+
+	// R is the block size, between 1 and 32
+	R int
+
+	// P is the parallelization, between 1 and 16
+	P int
+}
+
+const (
+	defaultKDFN = 32768
+	defaultKDFR = 8
+	defaultKDFP = 1
+
+	minKDFN      = 1 << 14
+	maxKDFN      = 1 << 20
+	maxKDFR      = 32
+	maxKDFP      = 16
+	maxKDFMemory = 1 << 30
+
+	// kdfHeaderSize is how many bytes the header grows by when
+	// flagKDFParams is set: two digit hex log2(N), R and P
+	kdfHeaderSize = 6
+)
+
+// withDefaults returns p with every zero field replaced by its default
+func (p KDFParams) withDefaults() KDFParams {
+	if p.N == 0 {
+		p.N = defaultKDFN
+	}
+	if p.R == 0 {
+		p.R = defaultKDFR
+	}
+	if p.P == 0 {
+		p.P = defaultKDFP
+	}
+	return p
+}
+
+// check reports ErrInvalidKDFParams unless p, defaults applied, is
+// within the bounds documented on KDFParams
+func (p KDFParams) check() error {
+	p = p.withDefaults()
+	switch {
+	case p.N < minKDFN || p.N > maxKDFN || p.N&(p.N-1) != 0:
+		return fmt.Errorf("%w: N is %d, need a power of two from %d to %d", ErrInvalidKDFParams, p.N, minKDFN, maxKDFN)
+	case p.R < 1 || p.R > maxKDFR:
+		return fmt.Errorf("%w: R is %d, need 1 to %d", ErrInvalidKDFParams, p.R, maxKDFR)
+	case p.P < 1 || p.P > maxKDFP:
+		return fmt.Errorf("%w: P is %d, need 1 to %d", ErrInvalidKDFParams, p.P, maxKDFP)
+	case 128*p.N*p.R > maxKDFMemory:
+		return fmt.Errorf("%w: N*R needs %d MiB, limit is %d MiB", ErrInvalidKDFParams,
+			128*p.N*p.R>>20, maxKDFMemory>>20)
+	}
+	return nil
+}
+
+// headerString renders p the way it is stored in a header
+func (p KDFParams) headerString() string {
+	return fmt.Sprintf("%02x%02x%02x", bits.TrailingZeros(uint(p.N)), p.R, p.P)
+}
+
+// parseKDFHeader reads the parameters headerString wrote from the
+// start of s and checks them, a file shouldn't be able to make its
+// reader spend unbounded memory
+func parseKDFHeader(s string) (p KDFParams, err error) {
+	var fields [3]byte
+	for i := range fields {
+		fields[i], err = parseHeaderByte(s, i)
+		if err != nil {
+			return p, err
+		}
+	}
+	if fields[0] >= 32 {
+		return p, fmt.Errorf("%w: log2(N) is %d", ErrInvalidKDFParams, fields[0])
+	}
+	p = KDFParams{N: 1 << fields[0], R: int(fields[1]), P: int(fields[2])}
+	return p, p.check()
+}
+
+// withKDF records the vault's KDF parameters in h, replacing any h
+// already had. Vaults using the defaults leave them out of the
+// header so their files read with releases that predate KDFParams.
+func (v *Vault) withKDF(h header) (header, error) {
+	h.flags &^= flagKDFParams
+	h.kdf = KDFParams{}
+	if v.kdf == (KDFParams{}) {
+		return h, nil
+	}
+	if err := v.kdf.check(); err != nil {
+		return h, err
+	}
+	h.flags |= flagKDFParams
+	h.kdf = v.kdf.withDefaults()
+	return h, nil
+}
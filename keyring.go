@@ -0,0 +1,201 @@
+package uggsec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/zalando/go-keyring"
+	"strings"
+)
+
+// keyringProvider is the subset of OS keyring operations a Vault
+// needs. Implementations should return keyring.ErrNotFound when no
+// secret exists for the service and user so callers can tell a
+// missing secret apart from a keyring that doesn't work at all.
+type keyringProvider interface {
+	Get(service, user string) (string, error)
+	Set(service, user, password string) error
+	Delete(service, user string) error
+}
+
+// goKeyring stores secrets in the OS keyring via
+// github.com/zalando/go-keyring
+type goKeyring struct{}
+
+func (goKeyring) Get(service, user string) (string, error) {
+	return keyring.Get(service, user)
+}
+
+func (goKeyring) Set(service, user, password string) error {
+	return keyring.Set(service, user, password)
+}
+
+func (goKeyring) Delete(service, user string) error {
+	return keyring.Delete(service, user)
+}
+
+// keyringBackend is the provider handed to vaults created by
+// InitKeyring. Tests swap it out for a fake so keyring behavior
+// (including failures) can be exercised without touching the OS.
+var keyringBackend keyringProvider = goKeyring{}
+
+func (v *Vault) getPasswordKeyring() (password string, err error) {
+	return keyringPassword(v.ring, v.service, v.user)
+}
+
+// getPasswordKeyringContext runs getPasswordKeyring but stops
+// waiting on it as soon as ctx is done. Keyring lookups can't be
+// interrupted so the lookup itself is left to finish in the
+// background, working only on the ring and labels copied here while
+// the caller still holds the vault's lock.
+func (v *Vault) getPasswordKeyringContext(ctx context.Context) (password string, err error) {
+	if ctx.Done() == nil {
+		return v.getPasswordKeyring()
+	}
+	ring, service, user := v.ring, v.service, v.user
+	type result struct {
+		password string
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		password, err := keyringPassword(ring, service, user)
+		done <- result{password, err}
+	}()
+	select {
+	case r := <-done:
+		return r.password, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// keyringPassword fetches the password stored in ring under service
+// and user
+func keyringPassword(ring keyringProvider, service, user string) (password string, err error) {
+	password, err = ring.Get(service, user)
+	if errors.Is(err, keyring.ErrNotFound) {
+		err = fmt.Errorf("%w for service %q user %q", ErrNoKeyringSecret, service, user)
+	}
+	// some backends will store an empty string, which is no more
+	// use as a password than a missing entry
+	if err == nil && password == "" {
+		err = fmt.Errorf("%w for service %q user %q, the stored secret is empty", ErrNoKeyringSecret, service, user)
+	}
+	return password, classifyKeyringError(err)
+}
+
+func (v *Vault) initKeyring() (err error) {
+	password, err := v.newPassword()
+	if err != nil {
+		return err
+	}
+	return classifyKeyringError(v.ring.Set(v.service, v.user, password))
+}
+
+// MigrateKeyring moves the password a keyring vault stores under
+// oldService and oldUser to newService and newUser, for programs
+// that rename their keyring labels. The secret is copied to the new
+// label before the old one is deleted so it's never missing. If the
+// new label already holds a secret ErrKeyringSecretExists is
+// returned unless force is set, in which case it's overwritten. If
+// the old label holds nothing ErrNoKeyringSecret is returned.
+func MigrateKeyring(oldService, oldUser, newService, newUser string, force bool) error {
+	if oldService == "" || oldUser == "" || newService == "" || newUser == "" {
+		return fmt.Errorf("%w: got service %q user %q and service %q user %q",
+			ErrMissingKeyringLabel, oldService, oldUser, newService, newUser)
+	}
+	if oldService == newService && oldUser == newUser {
+		return nil
+	}
+	ring := newChunkedKeyring(keyringBackend)
+	password, err := ring.Get(oldService, oldUser)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("%w for service %q user %q", ErrNoKeyringSecret, oldService, oldUser)
+	}
+	if err != nil {
+		return classifyKeyringError(err)
+	}
+	if password == "" {
+		return fmt.Errorf("%w for service %q user %q, the stored secret is empty", ErrNoKeyringSecret, oldService, oldUser)
+	}
+	if !force {
+		_, err = ring.Get(newService, newUser)
+		if err == nil {
+			return fmt.Errorf("%w: service %q user %q", ErrKeyringSecretExists, newService, newUser)
+		}
+		if !errors.Is(err, keyring.ErrNotFound) {
+			return classifyKeyringError(err)
+		}
+	}
+	log("Debug", "MigrateKeyring(), copying secret to new label", "service", newService, "user", newUser)
+	err = ring.Set(newService, newUser, password)
+	if err != nil {
+		return classifyKeyringError(err)
+	}
+	if rotatedAt, err := ring.Get(oldService, oldUser+rotatedAtSuffix); err == nil {
+		if err = ring.Set(newService, newUser+rotatedAtSuffix, rotatedAt); err == nil {
+			ring.Delete(oldService, oldUser+rotatedAtSuffix)
+		}
+	}
+	log("Debug", "MigrateKeyring(), deleting old label", "service", oldService, "user", oldUser)
+	return classifyKeyringError(ring.Delete(oldService, oldUser))
+}
+
+// keyringErrorPatterns maps fragments of the error messages the
+// go-keyring backends produce to the error they indicate. go-keyring
+// passes most backend errors through untyped so matching the text is
+// the only option. The lists cover secret-service over DBus on Linux,
+// the security command on macOS (whose exit status 36 means the
+// keychain is locked and 51 or 128 that the user refused) and the
+// credential manager on Windows. They are checked in order.
+var keyringErrorPatterns = []struct {
+	kind      error
+	fragments []string
+}{
+	{ErrKeyringLocked, []string{
+		"failed to unlock",
+		"is locked",
+		"interaction is not allowed",
+		"exit status 36",
+	}},
+	{ErrKeyringPermission, []string{
+		"permission denied",
+		"access denied",
+		"access is denied",
+		"accessdenied",
+		"not authorized",
+		"dismissed",
+		"exit status 51",
+		"exit status 128",
+	}},
+	{ErrKeyringUnavailable, []string{
+		"unsupported platform",
+		"dbus",
+		"d-bus",
+		"org.freedesktop.secrets",
+		"executable file not found",
+		"no such file or directory",
+		"connection refused",
+	}},
+}
+
+// classifyKeyringError wraps err from the keyring backend with
+// ErrKeyringUnavailable, ErrKeyringLocked or ErrKeyringPermission
+// when it can tell which applies, keeping the original message.
+// Errors this package already produced and ones it doesn't recognize
+// are returned unchanged.
+func classifyKeyringError(err error) error {
+	if err == nil || errors.Is(err, ErrNoKeyringSecret) {
+		return err
+	}
+	msg := strings.ToLower(err.Error())
+	for _, p := range keyringErrorPatterns {
+		for _, f := range p.fragments {
+			if strings.Contains(msg, f) {
+				return fmt.Errorf("%w: %v", p.kind, err)
+			}
+		}
+	}
+	return err
+}
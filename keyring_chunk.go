@@ -0,0 +1,191 @@
+package uggsec
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zalando/go-keyring"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Some keyring backends cap how long a secret can be. Secrets over
+// the limit are split across several keyring entries: the chunks
+// are stored under the vault's user label with a numbered suffix and
+// the entry under the label itself holds a marker saying how many
+// there are, so a secret of any length reads back transparently.
+//
+// Every Set writes its chunks under a new generation's labels and
+// only then switches the marker over, so a Set that fails halfway
+// leaves the previous secret readable. Markers written before
+// generations existed hold just the count and name generation 0,
+// whose chunks have no generation in their labels.
+//
+// The limits handled are:
+//
+//   - windows: the Credential Manager stores at most 2560 bytes
+//     (CRED_MAX_CREDENTIAL_BLOB_SIZE) per credential
+//   - darwin: go-keyring hex encodes the secret and passes it on a
+//     single line to `security -i`, which reads at most 4096 bytes,
+//     so roughly half of what's left after the labels
+//
+// Linux secret-service has no practical limit and nothing is split.
+const (
+	chunkMarker = "uggsec-chunked:"
+	chunkSuffix = ".uggsec-chunk-"
+)
+
+// chunkedKeyring splits secrets that are too long for the backend
+// it wraps
+type chunkedKeyring struct {
+	ring keyringProvider
+
+	// limit returns the longest secret the backend accepts for the
+	// labels, or zero when there is no limit
+	limit func(service, user string) int
+}
+
+func newChunkedKeyring(ring keyringProvider) chunkedKeyring {
+	return chunkedKeyring{ring: ring, limit: keyringValueLimit}
+}
+
+// keyringValueLimit returns the longest secret the OS keyring on
+// this platform accepts for the labels, or zero when it has no limit
+func keyringValueLimit(service, user string) int {
+	switch runtime.GOOS {
+	case "windows":
+		return 2560
+	case "darwin":
+		// leave room for the command, quoting and encoding prefix
+		return (4096 - 128 - len(service) - len(user)) / 2
+	}
+	return 0
+}
+
+func (c chunkedKeyring) Get(service, user string) (string, error) {
+	value, err := c.ring.Get(service, user)
+	if err != nil || !strings.HasPrefix(value, chunkMarker) {
+		return value, err
+	}
+	gen, n, err := parseChunkMarker(value)
+	if err != nil {
+		return "", fmt.Errorf("reading keyring secret: bad chunk marker %q", value)
+	}
+	var b strings.Builder
+	for k := 1; k <= n; k++ {
+		chunk, err := c.ring.Get(service, chunkUser(user, gen, k))
+		if err != nil {
+			return "", fmt.Errorf("reading keyring secret chunk %d of %d: %v", k, n, err)
+		}
+		b.WriteString(chunk)
+	}
+	return b.String(), nil
+}
+
+func (c chunkedKeyring) Set(service, user, password string) error {
+	oldGen, oldN := c.chunks(service, user)
+	limit := c.limit(service, user)
+	if limit < 0 || (limit > 0 && limit < len(chunkMarker)+16) {
+		return fmt.Errorf("%w: service and user labels leave no room for a secret", ErrKeyringValueTooLong)
+	}
+	// a secret that looks like a marker is chunked even when there's
+	// no limit, otherwise Get would take it for one
+	gen, n := 0, 0
+	if (limit > 0 && len(password) > limit) || strings.HasPrefix(password, chunkMarker) {
+		size := limit
+		if size == 0 {
+			size = len(password)
+		}
+		gen = oldGen + 1
+		for start := 0; start < len(password); start += size {
+			end := start + size
+			if end > len(password) {
+				end = len(password)
+			}
+			n++
+			err := c.ring.Set(service, chunkUser(user, gen, n), password[start:end])
+			if err != nil {
+				c.deleteChunks(service, user, gen, n)
+				return c.setError(err, len(password))
+			}
+		}
+		password = chunkMarker + strconv.Itoa(gen) + "/" + strconv.Itoa(n)
+	}
+	err := c.ring.Set(service, user, password)
+	if err != nil {
+		c.deleteChunks(service, user, gen, n)
+		return c.setError(err, len(password))
+	}
+	// the marker no longer points at the previous secret's chunks
+	c.deleteChunks(service, user, oldGen, oldN)
+	return nil
+}
+
+func (c chunkedKeyring) Delete(service, user string) error {
+	gen, n := c.chunks(service, user)
+	c.deleteChunks(service, user, gen, n)
+	return c.ring.Delete(service, user)
+}
+
+// chunks returns the generation and number of chunks the secret
+// stored under the labels is split into, zero chunks if it isn't
+// split or can't be read
+func (c chunkedKeyring) chunks(service, user string) (gen, n int) {
+	value, err := c.ring.Get(service, user)
+	if err != nil || !strings.HasPrefix(value, chunkMarker) {
+		return 0, 0
+	}
+	gen, n, err = parseChunkMarker(value)
+	if err != nil {
+		return 0, 0
+	}
+	return gen, n
+}
+
+// parseChunkMarker returns the generation and chunk count held by
+// a marker, either "<gen>/<count>" or, from before generations, just
+// "<count>" after the chunkMarker prefix
+func parseChunkMarker(value string) (gen, n int, err error) {
+	value = strings.TrimPrefix(value, chunkMarker)
+	if i := strings.IndexByte(value, '/'); i >= 0 {
+		gen, err = strconv.Atoi(value[:i])
+		if err != nil {
+			return 0, 0, err
+		}
+		value = value[i+1:]
+	}
+	n, err = strconv.Atoi(value)
+	return gen, n, err
+}
+
+// deleteChunks removes chunks 1 through n of generation gen, ignoring
+// failures since a leftover chunk is harmless
+func (c chunkedKeyring) deleteChunks(service, user string, gen, n int) {
+	for k := 1; k <= n; k++ {
+		err := c.ring.Delete(service, chunkUser(user, gen, k))
+		if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			log("Debug", "deleteChunks(), unable to delete keyring chunk", "chunk", k, "error", err.Error())
+		}
+	}
+}
+
+// setError reports a failed Set that looks like the backend
+// rejecting the secret's size as ErrKeyringValueTooLong
+func (c chunkedKeyring) setError(err error, size int) error {
+	msg := strings.ToLower(err.Error())
+	for _, f := range []string{"too long", "too big", "too large", "parameter is incorrect"} {
+		if strings.Contains(msg, f) {
+			return fmt.Errorf("%w: %d bytes: %v", ErrKeyringValueTooLong, size, err)
+		}
+	}
+	return err
+}
+
+// chunkUser returns the user label chunk k of generation gen is
+// stored under
+func chunkUser(user string, gen, k int) string {
+	if gen == 0 {
+		return user + chunkSuffix + strconv.Itoa(k)
+	}
+	return user + chunkSuffix + strconv.Itoa(gen) + "-" + strconv.Itoa(k)
+}
@@ -0,0 +1,123 @@
+package uggsec
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// failingRing is a mapRing whose Set, and Delete when failDelete is
+// set, fails for user labels with the given prefix
+type failingRing struct {
+	mapRing
+	failUser   string
+	failDelete bool
+}
+
+func (f failingRing) Set(service, user, password string) error {
+	if f.failUser != "" && strings.HasPrefix(user, f.failUser) {
+		return errInjected
+	}
+	return f.mapRing.Set(service, user, password)
+}
+
+func (f failingRing) Delete(service, user string) error {
+	if f.failDelete && strings.HasPrefix(user, f.failUser) {
+		return errInjected
+	}
+	return f.mapRing.Delete(service, user)
+}
+
+func fixedLimit(n int) func(service, user string) int {
+	return func(service, user string) int { return n }
+}
+
+func TestChunkedKeyringRoundTrip(t *testing.T) {
+	m := mapRing{}
+	c := chunkedKeyring{ring: m, limit: fixedLimit(40)}
+	long := strings.Repeat("0123456789", 10)
+	if err := c.Set("svc", "usr", long); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 4 {
+		t.Fatalf("stored %d entries, want a marker and 3 chunks", len(m))
+	}
+	if got, err := c.Get("svc", "usr"); err != nil || got != long {
+		t.Fatalf("Get() = %q, %v", got, err)
+	}
+	if err := c.Set("svc", "usr", "short"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 1 || m["svc/usr"] != "short" {
+		t.Fatalf("old chunks left behind: %v", m)
+	}
+	if err := c.Set("svc", "usr", long); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete("svc", "usr"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 0 {
+		t.Fatalf("Delete() left %v", m)
+	}
+}
+
+func TestChunkedKeyringFailedSetKeepsOldSecret(t *testing.T) {
+	m := mapRing{}
+	c := chunkedKeyring{ring: m, limit: fixedLimit(40)}
+	old := strings.Repeat("a", 100)
+	if err := c.Set("svc", "usr", old); err != nil {
+		t.Fatal(err)
+	}
+	// the new secret's second chunk fails, after its first was stored
+	c.ring = failingRing{mapRing: m, failUser: chunkUser("usr", 2, 2)}
+	if err := c.Set("svc", "usr", strings.Repeat("b", 100)); !errors.Is(err, errInjected) {
+		t.Fatalf("Set() = %v, want the injected failure", err)
+	}
+	if got, err := c.Get("svc", "usr"); err != nil || got != old {
+		t.Fatalf("Get() after failed Set = %q, %v, want the old secret", got, err)
+	}
+	if len(m) != 4 {
+		t.Fatalf("failed Set left %d entries, want the old marker and 3 chunks", len(m))
+	}
+	// and a failing marker write leaves the old secret too
+	c.ring = failingRing{mapRing: m, failUser: "usr"}
+	if err := c.Set("svc", "usr", strings.Repeat("b", 100)); !errors.Is(err, errInjected) {
+		t.Fatalf("Set() = %v, want the injected failure", err)
+	}
+	if got, err := c.Get("svc", "usr"); err != nil || got != old {
+		t.Fatalf("Get() after failed Set = %q, %v, want the old secret", got, err)
+	}
+}
+
+func TestChunkedKeyringReadsMarkerWithoutGeneration(t *testing.T) {
+	m := mapRing{
+		"svc/usr":                     chunkMarker + "2",
+		"svc/usr" + chunkSuffix + "1": "first",
+		"svc/usr" + chunkSuffix + "2": "second",
+	}
+	c := chunkedKeyring{ring: m, limit: fixedLimit(40)}
+	if got, err := c.Get("svc", "usr"); err != nil || got != "firstsecond" {
+		t.Fatalf("Get() = %q, %v", got, err)
+	}
+	if err := c.Set("svc", "usr", "short"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 1 {
+		t.Fatalf("old chunks left behind: %v", m)
+	}
+}
+
+func TestChunkedKeyringMarkerLookalike(t *testing.T) {
+	for _, limit := range []int{0, 40} {
+		m := mapRing{}
+		c := chunkedKeyring{ring: m, limit: fixedLimit(limit)}
+		password := chunkMarker + "3"
+		if err := c.Set("svc", "usr", password); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := c.Get("svc", "usr"); err != nil || got != password {
+			t.Fatalf("limit %d: Get() = %q, %v, want %q", limit, got, err, password)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package uggsec
+
+import (
+	"context"
+	"errors"
+	"github.com/zalando/go-keyring"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapRing is an in-memory keyringProvider keyed by "service/user"
+type mapRing map[string]string
+
+func (m mapRing) Get(service, user string) (string, error) {
+	secret, ok := m[service+"/"+user]
+	if !ok {
+		return "", keyring.ErrNotFound
+	}
+	return secret, nil
+}
+
+func (m mapRing) Set(service, user, password string) error {
+	m[service+"/"+user] = password
+	return nil
+}
+
+func (m mapRing) Delete(service, user string) error {
+	if _, ok := m[service+"/"+user]; !ok {
+		return keyring.ErrNotFound
+	}
+	delete(m, service+"/"+user)
+	return nil
+}
+
+// newKeyringTestVault returns a keyring vault with the labels
+// "svc" and "usr" whose password is kept in ring
+func newKeyringTestVault(t *testing.T, ring keyringProvider) *Vault {
+	t.Helper()
+	useKeyring(t, ring)
+	v, err := InitKeyring(&VaultInput{
+		Filename:  filepath.Join(t.TempDir(), "vault"),
+		Service:   "svc",
+		User:      "usr",
+		KDFParams: KDFParams{N: minKDFN, R: 1, P: 1},
+	})
+	if err != nil {
+		t.Fatalf("InitKeyring: %v", err)
+	}
+	return v
+}
+
+// useKeyring swaps ring in as the keyringBackend for the rest of the
+// test
+func useKeyring(t *testing.T, ring keyringProvider) {
+	t.Helper()
+	old := keyringBackend
+	keyringBackend = ring
+	t.Cleanup(func() { keyringBackend = old })
+}
+
+// errRing is a keyring whose every call fails with err
+type errRing struct{ err error }
+
+func (r errRing) Get(service, user string) (string, error) { return "", r.err }
+
+func (r errRing) Set(service, user, password string) error { return r.err }
+
+func (r errRing) Delete(service, user string) error { return r.err }
+
+func TestInitKeyringCreatesPassword(t *testing.T) {
+	ring := mapRing{}
+	v := newKeyringTestVault(t, ring)
+	stored := ring["svc/usr"]
+	if len(stored) < minPasswordLength {
+		t.Fatalf("InitKeyring() stored %d character password", len(stored))
+	}
+	if err := v.Write("x"); err != nil {
+		t.Fatal(err)
+	}
+	// a second vault on the same labels finds the password again
+	again := newKeyringTestVault(t, ring)
+	again.filename = v.filename
+	if got, err := again.Read(); err != nil || got != "x" {
+		t.Fatalf("Read() = %q, %v", got, err)
+	}
+	if ring["svc/usr"] != stored {
+		t.Fatal("second InitKeyring() replaced the stored password")
+	}
+}
+
+func TestInitKeyringReadOnlyWithoutSecret(t *testing.T) {
+	for name, ring := range map[string]mapRing{
+		"missing": {},
+		"empty":   {"svc/usr": ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			useKeyring(t, ring)
+			_, err := InitKeyring(&VaultInput{
+				Filename: filepath.Join(t.TempDir(), "vault"),
+				Service:  "svc",
+				User:     "usr",
+				ReadOnly: true,
+			})
+			if !errors.Is(err, ErrNoKeyringSecret) {
+				t.Fatalf("InitKeyring() = %v, want ErrNoKeyringSecret", err)
+			}
+			if ring["svc/usr"] != "" {
+				t.Fatal("read-only InitKeyring() stored a password")
+			}
+		})
+	}
+}
+
+func TestInitKeyringBackendFailure(t *testing.T) {
+	useKeyring(t, errRing{errors.New("The name org.freedesktop.secrets was not provided by any .service files")})
+	_, err := InitKeyring(&VaultInput{
+		Filename: filepath.Join(t.TempDir(), "vault"),
+		Service:  "svc",
+		User:     "usr",
+	})
+	if !errors.Is(err, ErrKeyringUnavailable) {
+		t.Fatalf("InitKeyring() = %v, want ErrKeyringUnavailable", err)
+	}
+}
+
+func TestClassifyKeyringError(t *testing.T) {
+	for _, c := range []struct {
+		msg  string
+		want error
+	}{
+		// secret-service over DBus on Linux
+		{"The name org.freedesktop.secrets was not provided by any .service files", ErrKeyringUnavailable},
+		{"dbus: couldn't determine address of session bus", ErrKeyringUnavailable},
+		{"failed to unlock correct collection '/org/freedesktop/secrets/aliases/default'", ErrKeyringLocked},
+		{"prompt dismissed", ErrKeyringPermission},
+		// the security command on macOS
+		{`exec: "/usr/bin/security": executable file not found in $PATH`, ErrKeyringUnavailable},
+		{"exit status 36", ErrKeyringLocked},
+		{"exit status 51", ErrKeyringPermission},
+		// credential manager on Windows
+		{"Access is denied.", ErrKeyringPermission},
+		{"go-keyring: unsupported platform", ErrKeyringUnavailable},
+	} {
+		err := classifyKeyringError(errors.New(c.msg))
+		if !errors.Is(err, c.want) {
+			t.Errorf("classifyKeyringError(%q) = %v, want %v", c.msg, err, c.want)
+		}
+		if !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("classifyKeyringError(%q) dropped the original message: %v", c.msg, err)
+		}
+	}
+	other := errors.New("something else entirely")
+	if err := classifyKeyringError(other); err != other {
+		t.Errorf("unrecognized error came back as %v", err)
+	}
+	if err := classifyKeyringError(nil); err != nil {
+		t.Errorf("classifyKeyringError(nil) = %v", err)
+	}
+}
+
+// countRing counts the calls made to the keyring it wraps, and
+// makes each Get take delay to stand in for a real keyring's latency
+type countRing struct {
+	keyringProvider
+	calls *int
+	delay time.Duration
+}
+
+func (r countRing) Get(service, user string) (string, error) {
+	*r.calls++
+	time.Sleep(r.delay)
+	return r.keyringProvider.Get(service, user)
+}
+
+func (r countRing) Set(service, user, password string) error {
+	*r.calls++
+	return r.keyringProvider.Set(service, user, password)
+}
+
+func (r countRing) Delete(service, user string) error {
+	*r.calls++
+	return r.keyringProvider.Delete(service, user)
+}
+
+func TestEmptyKeyringLabelsRejected(t *testing.T) {
+	calls := 0
+	useKeyring(t, countRing{keyringProvider: mapRing{}, calls: &calls})
+	filename := filepath.Join(t.TempDir(), "vault")
+	for _, labels := range [][2]string{{"", ""}, {"svc", ""}, {"", "usr"}} {
+		i := &VaultInput{Filename: filename, Service: labels[0], User: labels[1]}
+		if _, err := InitKeyring(i); !errors.Is(err, ErrMissingKeyringLabel) {
+			t.Errorf("InitKeyring(%q, %q) = %v, want ErrMissingKeyringLabel", labels[0], labels[1], err)
+		}
+		if _, err := InitSmart(i); !errors.Is(err, ErrMissingKeyringLabel) {
+			t.Errorf("InitSmart(%q, %q) = %v, want ErrMissingKeyringLabel", labels[0], labels[1], err)
+		}
+	}
+	if calls != 0 {
+		t.Fatalf("%d keyring calls made for empty labels", calls)
+	}
+}
+
+// gateRing is a mapRing that's safe for concurrent use and can hold
+// the answer to its next Get until the test releases it
+type gateRing struct {
+	mu       sync.Mutex
+	ring     mapRing
+	gate     chan struct{}
+	finished chan struct{}
+}
+
+// holdNextGet makes the next Get wait until release is called
+func (r *gateRing) holdNextGet() (release func(), finished <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	gate := make(chan struct{})
+	r.gate, r.finished = gate, make(chan struct{})
+	return func() { close(gate) }, r.finished
+}
+
+func (r *gateRing) Get(service, user string) (string, error) {
+	r.mu.Lock()
+	gate, finished := r.gate, r.finished
+	r.gate, r.finished = nil, nil
+	password, err := r.ring.Get(service, user)
+	r.mu.Unlock()
+	if gate != nil {
+		defer close(finished)
+		<-gate
+	}
+	return password, err
+}
+
+func (r *gateRing) Set(service, user, password string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ring.Set(service, user, password)
+}
+
+func (r *gateRing) Delete(service, user string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ring.Delete(service, user)
+}
+
+func TestAbandonedKeyringLookupDoesNotCache(t *testing.T) {
+	ring := &gateRing{ring: mapRing{}}
+	useKeyring(t, ring)
+	v, err := InitKeyring(&VaultInput{
+		Filename:         filepath.Join(t.TempDir(), "vault"),
+		Service:          "svc",
+		User:             "usr",
+		KDFParams:        KDFParams{N: minKDFN, R: 1, P: 1},
+		PasswordCacheTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Write("old"); err != nil {
+		t.Fatal(err)
+	}
+	v.pwCache.clear()
+	release, finished := ring.holdNextGet()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := v.ReadContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ReadContext() = %v, want the deadline error", err)
+	}
+	// the abandoned lookup returns the old password after Rotate
+	// has replaced it and must not put it back in the cache
+	if err := v.Rotate("a rotated password of 32 bytes!!"); err != nil {
+		t.Fatal(err)
+	}
+	release()
+	<-finished
+	// give a stale put the chance to happen before checking for it
+	time.Sleep(50 * time.Millisecond)
+	if got, err := v.Read(); err != nil || got != "old" {
+		t.Fatalf("Read() after Rotate = %q, %v", got, err)
+	}
+}
+
+func TestMigrateKeyring(t *testing.T) {
+	ring := mapRing{}
+	v := newKeyringTestVault(t, ring)
+	if err := v.Write("moved labels"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateKeyring("svc", "usr", "svc2", "usr2", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ring["svc/usr"]; ok {
+		t.Fatal("old keyring entry left behind")
+	}
+	moved, err := InitKeyring(&VaultInput{Filename: v.filename, Service: "svc2", User: "usr2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := moved.Read(); err != nil || got != "moved labels" {
+		t.Fatalf("Read() under the new labels = %q, %v", got, err)
+	}
+	if err := MigrateKeyring("svc", "usr", "svc3", "usr3", false); !errors.Is(err, ErrNoKeyringSecret) {
+		t.Fatalf("MigrateKeyring() from an empty label = %v, want ErrNoKeyringSecret", err)
+	}
+	ring["svc3/usr3"] = "already taken"
+	if err := MigrateKeyring("svc2", "usr2", "svc3", "usr3", false); !errors.Is(err, ErrKeyringSecretExists) {
+		t.Fatalf("MigrateKeyring() onto a used label = %v, want ErrKeyringSecretExists", err)
+	}
+	if err := MigrateKeyring("svc2", "usr2", "svc3", "usr3", true); err != nil {
+		t.Fatalf("MigrateKeyring(force) = %v", err)
+	}
+}
+
+func TestRegeneratePassword(t *testing.T) {
+	ring := mapRing{}
+	v := newKeyringTestVault(t, ring)
+	if err := v.Write("split workflow"); err != nil {
+		t.Fatal(err)
+	}
+	// the machine that manages the file still has the old password
+	manager := newTestVault(t, &VaultInput{Filename: v.filename, Password: Secret(ring["svc/usr"])})
+	password, err := v.RegeneratePassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ring["svc/usr"] != password {
+		t.Fatal("RegeneratePassword() didn't store the returned password")
+	}
+	if _, err := v.Read(); !errors.Is(err, ErrTampered) {
+		t.Fatalf("Read() after RegeneratePassword = %v, want ErrTampered", err)
+	}
+	if err := manager.Rotate(password); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.Read(); err != nil || got != "split workflow" {
+		t.Fatalf("Read() once re-encrypted with the returned password = %q, %v", got, err)
+	}
+}
+
+func TestRegeneratePasswordNeedsKeyring(t *testing.T) {
+	v := newTestVault(t, nil)
+	if _, err := v.RegeneratePassword(); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("RegeneratePassword() = %v, want ErrNotSupported", err)
+	}
+}
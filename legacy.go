@@ -0,0 +1,81 @@
+package uggsec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// legacyIV is the fixed IV older releases of this package used for
+// every file. It is only kept around to read those files: nothing is
+// ever encrypted with it, every write uses the current format with a
+// fresh random salt and nonce, so the IV reuse those releases
+// suffered from can't recur. Files they wrote still leak through the
+// shared IV until Migrate rewrites them.
+var legacyIV = []byte{35, 46, 57, 24, 85, 35, 24, 74, 87, 35, 88, 98, 66, 32, 14, 05}
+
+// openLegacy decrypts the vault's legacy format contents for
+// loadFromDisk when AllowLegacy is set, warning once per vault that
+// the format is deprecated. Otherwise, or when the vault binds its
+// contents to AssociatedData, which the legacy format can't honour,
+// ErrLegacyFormat is returned. It's also returned when the result
+// doesn't look like a legacy vault, since without authentication
+// that is the only sign of a file that isn't one. Only the primary
+// password is used, the legacy format never had a secondary one.
+func (v *Vault) openLegacy(data string) ([]byte, error) {
+	if !v.allowLegacy {
+		return nil, ErrLegacyFormat
+	}
+	if len(v.associatedData) > 0 {
+		return nil, fmt.Errorf("%w: legacy vaults can't be bound to AssociatedData", ErrLegacyFormat)
+	}
+	v.legacyNotice.Do(func() {
+		v.log("Warn", "openLegacy(), vault is in the deprecated legacy format, call Migrate to upgrade it", "file", v.filename)
+	})
+	password, err := v.getPrimaryPassword()
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(password)
+	contents, err := decryptLegacy(data, password)
+	if errors.Is(err, ErrTampered) {
+		return nil, fmt.Errorf("%w: %v", ErrLegacyFormat, err)
+	}
+	return contents, err
+}
+
+// decryptLegacy decrypts the headerless format written by older
+// releases: base64 of AES-CFB ciphertext keyed directly with the
+// password, which therefore has to be 16, 24 or 32 bytes long. The
+// format has no authentication, so the best that can be done against
+// a wrong password or a file that was never a vault is to insist on
+// what a legacy writer produced: canonical base64 of ciphertext that
+// decrypts to UTF-8 text, legacy vaults only ever held strings.
+// Anything else is reported as ErrTampered.
+func decryptLegacy(encrypted string, password []byte) ([]byte, error) {
+	switch len(password) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("%w: legacy vaults need a 16, 24 or %d byte password, got %d bytes",
+			ErrInvalidKeyLength, keySize, len(password))
+	}
+	block, err := aes.NewCipher(password)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || base64.StdEncoding.EncodeToString(cipherText) != encrypted {
+		return nil, fmt.Errorf("%w: not legacy vault contents, they aren't base64", ErrTampered)
+	}
+	cfb := cipher.NewCFBDecrypter(block, legacyIV)
+	plainText := make([]byte, len(cipherText))
+	cfb.XORKeyStream(plainText, cipherText)
+	if !utf8.Valid(plainText) {
+		wipe(plainText)
+		return nil, fmt.Errorf("%w: legacy contents didn't decrypt to text", ErrTampered)
+	}
+	return plainText, nil
+}
@@ -0,0 +1,146 @@
+package uggsec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// legacyPassword is the password testdata/legacy.vault was written
+// with by the pre-header release, which used it as the AES key
+const legacyPassword = "legacyfixturepasswordof32bytes!!"
+
+// copyLegacyFixture copies testdata/legacy.vault into a temp dir and
+// returns its path
+func copyLegacyFixture(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "legacy.vault"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "legacy.vault")
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestLegacyNeedsAllowLegacy(t *testing.T) {
+	name := copyLegacyFixture(t)
+	_, err := InitPassword(&VaultInput{Filename: name, Password: legacyPassword})
+	if !errors.Is(err, ErrLegacyFormat) {
+		t.Fatalf("got %v, want ErrLegacyFormat", err)
+	}
+	v, err := InitPassword(&VaultInput{Filename: name, Password: legacyPassword, AllowLegacy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := v.Read()
+	if err != nil || got != "hello from an old release" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
+
+func TestLegacyRejectedWithAssociatedData(t *testing.T) {
+	name := copyLegacyFixture(t)
+	_, err := InitPassword(&VaultInput{Filename: name, Password: legacyPassword, AllowLegacy: true,
+		AssociatedData: []byte("tenant-1")})
+	if !errors.Is(err, ErrLegacyFormat) {
+		t.Fatalf("got %v, want ErrLegacyFormat", err)
+	}
+}
+
+func TestLegacyRejectsImplausibleContents(t *testing.T) {
+	for name, data := range map[string]string{
+		"not base64":     "this is not a vault file\n",
+		"wrong password": "9WWw01ygYpiTTgLc0Q1zNqVp7Rq2afV1jQ==",
+		"random bytes":   "3q2+7wABAgP/",
+	} {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "v")
+			if err := os.WriteFile(file, []byte(data), 0600); err != nil {
+				t.Fatal(err)
+			}
+			password := "wrong password, but 32 bytes!!!!"
+			if name != "wrong password" {
+				password = legacyPassword
+			}
+			_, err := InitPassword(&VaultInput{Filename: file, Password: Secret(password), AllowLegacy: true})
+			if !errors.Is(err, ErrLegacyFormat) {
+				t.Fatalf("got %v, want ErrLegacyFormat", err)
+			}
+		})
+	}
+}
+
+func TestHeaderlessCurrentFileIsNotLegacy(t *testing.T) {
+	// a password that is a valid legacy key, so the legacy path
+	// really is attempted
+	v := newTestVault(t, &VaultInput{AllowLegacy: true, Password: "0123456789abcdef0123456789abcdef"})
+	if err := v.Write("secret"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// strip the header so only the payload is left
+	h, _, err := parseHeader(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(v.filename, data[h.size():], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Read(); !errors.Is(err, ErrLegacyFormat) {
+		t.Fatalf("got %v, want ErrLegacyFormat", err)
+	}
+}
+
+func TestMigrateLegacyFixture(t *testing.T) {
+	name := copyLegacyFixture(t)
+	v, err := InitPassword(&VaultInput{Filename: name, Password: legacyPassword, KDFParams: KDFParams{N: minKDFN, R: 1, P: 1}})
+	if !errors.Is(err, ErrLegacyFormat) {
+		t.Fatalf("InitPassword() = %v, want ErrLegacyFormat before migrating", err)
+	}
+	if err := v.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasHeader(string(data)) {
+		t.Fatal("Migrate() didn't write the current format")
+	}
+	if got, err := v.Read(); err != nil || got != "hello from an old release" {
+		t.Fatalf("Read() after Migrate = %q, %v", got, err)
+	}
+	// migrating again leaves the file alone
+	if err := v.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Fatal("second Migrate() rewrote the file")
+	}
+}
+
+func TestMigrateWrongPassword(t *testing.T) {
+	name := copyLegacyFixture(t)
+	v, _ := InitPassword(&VaultInput{Filename: name, Password: "not the fixture's password at all"})
+	if err := v.Migrate(); err == nil {
+		t.Fatal("Migrate() with the wrong password succeeded")
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasHeader(string(data)) {
+		t.Fatal("failed Migrate() rewrote the file")
+	}
+}
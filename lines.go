@@ -0,0 +1,44 @@
+package uggsec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WriteLines writes lines as the vault's contents, each one followed
+// by a newline, replacing anything previously stored. An empty slice
+// writes an empty vault. Lines can't contain line breaks themselves
+// since ReadLines couldn't tell them apart, so a line with "\n" or
+// "\r" in it is an error and nothing is written.
+func (v *Vault) WriteLines(lines []string) (err error) {
+	defer wrapOp("write", v.filename, &err)
+	var b strings.Builder
+	for n, line := range lines {
+		if strings.ContainsAny(line, "\r\n") {
+			return fmt.Errorf("line %d contains a line break", n+1)
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return v.Write(b.String())
+}
+
+// ReadLines decrypts the vault's contents and splits them into
+// lines. Both "\n" and "\r\n" line endings are accepted and the final
+// line doesn't need one, so contents written with Write read back
+// sensibly too. An empty vault gives an empty slice.
+func (v *Vault) ReadLines() (lines []string, err error) {
+	defer wrapOp("read", v.filename, &err)
+	contents, err := v.Read()
+	if err != nil {
+		return nil, err
+	}
+	if contents == "" {
+		return []string{}, nil
+	}
+	lines = strings.Split(strings.TrimSuffix(contents, "\n"), "\n")
+	for n, line := range lines {
+		lines[n] = strings.TrimSuffix(line, "\r")
+	}
+	return lines, nil
+}
@@ -0,0 +1,41 @@
+package uggsec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWriteReadLines(t *testing.T) {
+	v := newTestVault(t, nil)
+	want := []string{"first", "", "third"}
+	if err := v.WriteLines(want); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.ReadLines(); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadLines() = %q, %v, want %q", got, err, want)
+	}
+	if err := v.WriteLines([]string{"ok", "not\nok"}); err == nil {
+		t.Fatal("WriteLines() accepted a line with a line break")
+	}
+	if got, err := v.ReadLines(); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadLines() after a rejected WriteLines = %q, %v", got, err)
+	}
+}
+
+func TestReadLinesFromWrite(t *testing.T) {
+	v := newTestVault(t, nil)
+	for contents, want := range map[string][]string{
+		"":                  {},
+		"one":               {"one"},
+		"one\n":             {"one"},
+		"one\r\ntwo\r\n":    {"one", "two"},
+		"one\ntwo\r\nthree": {"one", "two", "three"},
+	} {
+		if err := v.Write(contents); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := v.ReadLines(); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadLines() of %q = %q, %v, want %q", contents, got, err, want)
+		}
+	}
+}
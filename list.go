@@ -0,0 +1,58 @@
+package uggsec
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxHeaderSize is the longest header any format version writes
+const maxHeaderSize = len(formatMagic) + 6 + kdfHeaderSize
+
+// ListVaults returns the paths of the files directly inside dir that
+// hold a vault written by this package, sorted by name, for tools
+// that show all of a user's encrypted files. Files are recognized by
+// their format header alone, nothing is decrypted and no password is
+// needed. Vaults in the legacy format have no header and aren't
+// found, nor are subdirectories. The backups KeepBackup makes and
+// temp files left by an interrupted write are skipped since they
+// belong to another vault in the list. Files that can't be opened
+// are skipped too.
+func ListVaults(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	vaults := []string{}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || strings.HasSuffix(name, backupSuffix) ||
+			(strings.HasPrefix(name, ".") && strings.Contains(name, ".tmp-")) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if isVaultFile(path) {
+			vaults = append(vaults, path)
+		}
+	}
+	return vaults, nil
+}
+
+// isVaultFile reports whether the file at path starts with a header
+// this package can read
+func isVaultFile(path string) bool {
+	f, err := fileBackend.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		log("Debug", "ListVaults(), skipping unreadable file", "file", path, "error", err.Error())
+		return false
+	}
+	defer f.Close()
+	buf := make([]byte, maxHeaderSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false
+	}
+	_, _, err = parseHeader(string(buf[:n]))
+	return err == nil
+}
@@ -0,0 +1,76 @@
+package uggsec
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestListVaults(t *testing.T) {
+	dir := t.TempDir()
+	a := newTestVault(t, &VaultInput{Filename: filepath.Join(dir, "a"), KeepBackup: true})
+	for _, contents := range []string{"one", "two"} {
+		if err := a.Write(contents); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := newTestVault(t, &VaultInput{Filename: filepath.Join(dir, "b")})
+	if err := b.WriteStream(strings.NewReader("streamed")); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := a.WriteDryRun("interrupted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := os.ReadFile(filepath.Join("testdata", "legacy.vault"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		".a.tmp-123": ciphertext,
+		"notes.txt":  "not a vault",
+		"empty":      "",
+		"legacy":     string(legacy),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "subdir"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ListVaults(dir)
+	if want := []string{a.filename, b.filename}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("ListVaults() = %q, %v, want %q", got, err, want)
+	}
+	if _, err := ListVaults(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Fatalf("ListVaults(missing) = %v, want a not exist error", err)
+	}
+}
+
+func TestIsVaultFile(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("recognized"); err != nil {
+		t.Fatal(err)
+	}
+	if !isVaultFile(v.filename) {
+		t.Error("isVaultFile() = false for a vault")
+	}
+	// a header on its own is enough, nothing is decrypted
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(t.TempDir(), "truncated")
+	if err := os.WriteFile(truncated, data[:maxHeaderSize], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !isVaultFile(truncated) {
+		t.Error("isVaultFile() = false for a vault cut short after its header")
+	}
+	if isVaultFile(filepath.Join(t.TempDir(), "missing")) {
+		t.Error("isVaultFile() = true for a missing file")
+	}
+}
@@ -0,0 +1,52 @@
+package uggsec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	defaultLockTimeout = 10 * time.Second
+	lockRetryInterval  = 50 * time.Millisecond
+)
+
+// lockFile takes the vault's cross-process lock when UseFileLock
+// was requested and returns a function that releases it. When file
+// locking is off the returned function does nothing.
+//
+// The lock is a Filename+".lock" sibling created with O_EXCL so it
+// works the same on every platform. It is advisory, only other
+// uggsec vaults with UseFileLock set will honor it. If a process
+// dies while holding the lock the file is left behind and has to
+// be removed by hand, the error returned on timeout names it.
+func (v *Vault) lockFile() (unlock func(), err error) {
+	return v.lockPath(v.filename)
+}
+
+// lockPath behaves like lockFile but locks filename rather than the
+// vault's own file
+func (v *Vault) lockPath(filename string) (unlock func(), err error) {
+	if !v.fileLock {
+		return func() {}, nil
+	}
+	lockName := filename + ".lock"
+	deadline := time.Now().Add(v.lockTimeout)
+	for {
+		f, err := fileBackend.OpenFile(lockName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			v.log("Debug", "lockFile(), acquired lock", "file", lockName)
+			return func() { fileBackend.Remove(lockName) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s held for more than %s", ErrLockTimeout, lockName, v.lockTimeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
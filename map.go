@@ -0,0 +1,160 @@
+package uggsec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// WriteMap JSON encodes m and writes it as the vault's contents,
+// replacing anything previously stored. This lets a single vault
+// hold several named secrets without callers having to invent
+// their own serialization on top of Write.
+func (v *Vault) WriteMap(m map[string]string) (err error) {
+	defer wrapOp("write", v.filename, &err)
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return v.WriteBytes(b)
+}
+
+// ReadMap decrypts the vault's contents and decodes them as a map
+// written by WriteMap. An empty vault decodes to an empty map.
+func (v *Vault) ReadMap() (m map[string]string, err error) {
+	defer wrapOp("read", v.filename, &err)
+	b, err := v.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	return decodeMap(b)
+}
+
+// Set stores value under key in the vault's map, keeping any other
+// keys already present. It reads, modifies and rewrites the whole
+// vault while holding the vault's lock so concurrent calls from
+// other goroutines don't lose each other's updates.
+func (v *Vault) Set(key, value string) (err error) {
+	defer wrapOp("write", v.filename, &err)
+	return v.updateMap(func(m map[string]string) error {
+		m[key] = value
+		return nil
+	})
+}
+
+// Get returns the value stored under key in the vault's map. If
+// the key isn't present then ErrKeyNotFound is returned.
+func (v *Vault) Get(key string) (value string, err error) {
+	defer wrapOp("read", v.filename, &err)
+	m, err := v.ReadMap()
+	if err != nil {
+		return "", err
+	}
+	value, ok := m[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrKeyNotFound, key)
+	}
+	return value, nil
+}
+
+// GetWithDefault behaves like Get but returns def instead of
+// ErrKeyNotFound when key isn't present. Other errors, such as the
+// vault failing to decrypt, are still returned.
+func (v *Vault) GetWithDefault(key, def string) (value string, err error) {
+	defer wrapOp("read", v.filename, &err)
+	value, err = v.Get(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return def, nil
+	}
+	return value, err
+}
+
+// GetInt returns the value stored under key parsed as a base 10
+// integer. A value that doesn't parse is reported with the
+// strconv error wrapped, a missing key as ErrKeyNotFound.
+func (v *Vault) GetInt(key string) (value int, err error) {
+	defer wrapOp("read", v.filename, &err)
+	s, err := v.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	value, err = strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("vault key %q: %w", key, err)
+	}
+	return value, nil
+}
+
+// GetBool behaves like GetInt but parses the value with
+// strconv.ParseBool, which accepts 1, t, true, 0, f, false and
+// their upper case forms.
+func (v *Vault) GetBool(key string) (value bool, err error) {
+	defer wrapOp("read", v.filename, &err)
+	s, err := v.Get(key)
+	if err != nil {
+		return false, err
+	}
+	value, err = strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("vault key %q: %w", key, err)
+	}
+	return value, nil
+}
+
+// GetDuration behaves like GetInt but parses the value with
+// time.ParseDuration, so it should look like "90s" or "1h30m".
+func (v *Vault) GetDuration(key string) (value time.Duration, err error) {
+	defer wrapOp("read", v.filename, &err)
+	s, err := v.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	value, err = time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("vault key %q: %w", key, err)
+	}
+	return value, nil
+}
+
+// updateMap decodes the vault's map, passes it to fn and writes it
+// back if fn succeeds, all while holding the vault's lock. A vault
+// with no file yet holds an empty map.
+func (v *Vault) updateMap(fn func(m map[string]string) error) (err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.writable(); err != nil {
+		return err
+	}
+	b, err := v.loadFromDisk(context.Background())
+	if err != nil && !errors.Is(err, ErrVaultNotFound) {
+		return err
+	}
+	m, err := decodeMap(b)
+	if err != nil {
+		return err
+	}
+	err = fn(m)
+	if err != nil {
+		return err
+	}
+	b, err = json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return v.writeBytes(context.Background(), b)
+}
+
+func decodeMap(b []byte) (m map[string]string, err error) {
+	m = make(map[string]string)
+	if len(b) == 0 {
+		return m, nil
+	}
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, fmt.Errorf("decoding vault map: %w", err)
+	}
+	return m, nil
+}
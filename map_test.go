@@ -0,0 +1,77 @@
+package uggsec
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newMapTestVault returns a vault holding a map with one well formed
+// and one malformed value for each typed getter
+func newMapTestVault(t *testing.T) *Vault {
+	t.Helper()
+	v := newTestVault(t, nil)
+	err := v.WriteMap(map[string]string{
+		"name":        "primary",
+		"port":        "5432",
+		"bad port":    "54x2",
+		"tls":         "true",
+		"bad tls":     "maybe",
+		"timeout":     "1m30s",
+		"bad timeout": "90",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestGetWithDefault(t *testing.T) {
+	v := newMapTestVault(t)
+	if got, err := v.GetWithDefault("name", "fallback"); err != nil || got != "primary" {
+		t.Fatalf("GetWithDefault(name) = %q, %v", got, err)
+	}
+	if got, err := v.GetWithDefault("missing", "fallback"); err != nil || got != "fallback" {
+		t.Fatalf("GetWithDefault(missing) = %q, %v, want the default", got, err)
+	}
+}
+
+func TestGetInt(t *testing.T) {
+	v := newMapTestVault(t)
+	if got, err := v.GetInt("port"); err != nil || got != 5432 {
+		t.Fatalf("GetInt(port) = %d, %v", got, err)
+	}
+	if _, err := v.GetInt("bad port"); !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatalf("GetInt(bad port) = %v, want strconv.ErrSyntax", err)
+	}
+	if _, err := v.GetInt("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("GetInt(missing) = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestGetBool(t *testing.T) {
+	v := newMapTestVault(t)
+	if got, err := v.GetBool("tls"); err != nil || !got {
+		t.Fatalf("GetBool(tls) = %t, %v", got, err)
+	}
+	if _, err := v.GetBool("bad tls"); !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatalf("GetBool(bad tls) = %v, want strconv.ErrSyntax", err)
+	}
+	if _, err := v.GetBool("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("GetBool(missing) = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestGetDuration(t *testing.T) {
+	v := newMapTestVault(t)
+	if got, err := v.GetDuration("timeout"); err != nil || got != 90*time.Second {
+		t.Fatalf("GetDuration(timeout) = %s, %v", got, err)
+	}
+	if _, err := v.GetDuration("bad timeout"); err == nil || errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("GetDuration(bad timeout) = %v, want a parse error", err)
+	}
+	if _, err := v.GetDuration("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("GetDuration(missing) = %v, want ErrKeyNotFound", err)
+	}
+}
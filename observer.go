@@ -0,0 +1,72 @@
+package uggsec
+
+import "time"
+
+// Observer receives timings for a vault's operations, for feeding
+// metrics such as Prometheus histograms without this package having
+// to depend on a metrics library. Set one with VaultInput.Observer.
+// Callbacks run synchronously on the calling goroutine, some with
+// the vault's lock held, so they should be quick and must not call
+// back into the vault.
+type Observer interface {
+	// OnRead is called after the vault's contents have been
+	// fetched and decrypted, including the reads that methods
+	// like Set and Append do before writing.
+	OnRead(dur time.Duration, err error)
+
+	// OnWrite is called after new contents have been encrypted
+	// and stored, including by Rotate and WriteStream.
+	OnWrite(dur time.Duration, err error)
+
+	// OnKeyring is called after each call to the OS keyring, op
+	// being one of "get", "set" or "delete".
+	OnKeyring(op string, dur time.Duration, err error)
+}
+
+// observeRead reports a read that began at start to the vault's
+// observer. Call it deferred, and only when there is an observer.
+func (v *Vault) observeRead(start time.Time, err *error) {
+	v.observer.OnRead(time.Since(start), *err)
+}
+
+// observeWrite is the write counterpart of observeRead
+func (v *Vault) observeWrite(start time.Time, err *error) {
+	v.observer.OnWrite(time.Since(start), *err)
+}
+
+// observedKeyring reports the timing of every call to the wrapped
+// provider to an Observer
+type observedKeyring struct {
+	ring     keyringProvider
+	observer Observer
+}
+
+// observeKeyring wraps ring so the vault's observer sees its calls,
+// returning ring itself when there is no observer
+func (v *Vault) observeKeyring(ring keyringProvider) keyringProvider {
+	if v.observer == nil {
+		return ring
+	}
+	return observedKeyring{ring: ring, observer: v.observer}
+}
+
+func (o observedKeyring) Get(service, user string) (string, error) {
+	start := time.Now()
+	password, err := o.ring.Get(service, user)
+	o.observer.OnKeyring("get", time.Since(start), err)
+	return password, err
+}
+
+func (o observedKeyring) Set(service, user, password string) error {
+	start := time.Now()
+	err := o.ring.Set(service, user, password)
+	o.observer.OnKeyring("set", time.Since(start), err)
+	return err
+}
+
+func (o observedKeyring) Delete(service, user string) error {
+	start := time.Now()
+	err := o.ring.Delete(service, user)
+	o.observer.OnKeyring("delete", time.Since(start), err)
+	return err
+}
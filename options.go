@@ -0,0 +1,108 @@
+package uggsec
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// Option configures a vault created with New.
+type Option func(*VaultInput)
+
+// New creates a vault stored in filename configured by opts. With
+// no password options the vault uses the OS keyring, exactly like
+// InitSmart does for a VaultInput, which needs WithKeyring for its
+// labels. Options that are added later don't change New's signature
+// so callers stay source compatible.
+//
+//	v, err := uggsec.New("secrets.txt", uggsec.WithEnvVar("UGGSECP"))
+func New(filename string, opts ...Option) (*Vault, error) {
+	i := &VaultInput{Filename: filename}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return InitSmart(i)
+}
+
+// WithKeyring stores the vault's password in the OS keyring under
+// the given service and user labels.
+func WithKeyring(service, user string) Option {
+	return func(i *VaultInput) {
+		i.Service = service
+		i.User = user
+	}
+}
+
+// WithEnvVar reads the vault's password from the named ENV var
+// instead of the OS keyring.
+func WithEnvVar(name string) Option {
+	return func(i *VaultInput) {
+		i.PasswordEnvVar = name
+	}
+}
+
+// WithoutKeyring keeps the vault from ever probing the OS keyring,
+// see VaultInput.DisableKeyring.
+func WithoutKeyring() Option {
+	return func(i *VaultInput) {
+		i.DisableKeyring = true
+	}
+}
+
+// WithPassword encrypts the vault with password directly instead of
+// one kept in the keyring or an ENV var.
+func WithPassword(password string) Option {
+	return func(i *VaultInput) {
+		i.Password = Secret(password)
+	}
+}
+
+// WithFileMode sets the permission the vault file is written with.
+func WithFileMode(m os.FileMode) Option {
+	return func(i *VaultInput) {
+		i.FileMode = m
+	}
+}
+
+// WithLogger sends the vault's log messages to l.
+func WithLogger(l Logger) Option {
+	return func(i *VaultInput) {
+		i.Logger = l
+	}
+}
+
+// WithFileLock enables the cross-process lock file, waiting at most
+// timeout for it (zero uses the default).
+func WithFileLock(timeout time.Duration) Option {
+	return func(i *VaultInput) {
+		i.UseFileLock = true
+		i.FileLockTimeout = timeout
+	}
+}
+
+// WithInsecureRandSource makes the vault draw its salts, nonces and
+// generated passwords from r instead of crypto/rand, for
+// reproducible tests only. See VaultInput.InsecureRandSource for
+// why it must never be used in production.
+func WithInsecureRandSource(r io.Reader) Option {
+	return func(i *VaultInput) {
+		i.InsecureRandSource = r
+	}
+}
+
+// WithClock makes the vault take the time from now instead of
+// time.Now, see VaultInput.Clock.
+func WithClock(now func() time.Time) Option {
+	return func(i *VaultInput) {
+		i.Clock = now
+	}
+}
+
+// withClock swaps the clock of an existing vault for now, for tests
+// that got v from a helper rather than building its VaultInput
+func (v *Vault) withClock(now func() time.Time) *Vault {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.nowFunc = now
+	return v
+}
@@ -0,0 +1,61 @@
+package uggsec
+
+import (
+	mrand "math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewOptions(t *testing.T) {
+	const envVar = "UGGSEC_OPTIONS_TEST"
+	logger := &recordLogger{}
+	src := mrand.New(mrand.NewSource(1))
+	fixed := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	password := WithPassword(testPassword)
+	for _, c := range []struct {
+		name  string
+		opts  []Option
+		check func(v *Vault) bool
+	}{
+		{"WithPassword", []Option{password}, func(v *Vault) bool {
+			return v.Mechanism() == MechanismPassword && string(v.password) == testPassword
+		}},
+		{"WithKeyring", []Option{WithKeyring("svc", "usr")}, func(v *Vault) bool {
+			return v.Mechanism() == MechanismKeyring && v.service == "svc" && v.user == "usr"
+		}},
+		{"WithEnvVar", []Option{WithEnvVar(envVar)}, func(v *Vault) bool {
+			return v.Mechanism() == MechanismEnvVar && v.passwordEnvVar == envVar
+		}},
+		{"WithoutKeyring", []Option{WithKeyring("svc", "usr"), WithoutKeyring(), WithEnvVar(envVar)}, func(v *Vault) bool {
+			return v.Mechanism() == MechanismEnvVar
+		}},
+		{"WithFileMode", []Option{password, WithFileMode(0640)}, func(v *Vault) bool {
+			return v.fileMode == 0640
+		}},
+		{"WithLogger", []Option{password, WithLogger(logger)}, func(v *Vault) bool {
+			return v.logger == logger
+		}},
+		{"WithFileLock", []Option{password, WithFileLock(time.Second)}, func(v *Vault) bool {
+			return v.fileLock && v.lockTimeout == time.Second
+		}},
+		{"WithInsecureRandSource", []Option{password, WithInsecureRandSource(src)}, func(v *Vault) bool {
+			return v.randSource == src
+		}},
+		{"WithClock", []Option{password, WithClock(func() time.Time { return fixed })}, func(v *Vault) bool {
+			return v.nowFunc().Equal(fixed)
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			useKeyring(t, mapRing{})
+			t.Setenv(envVar, testPassword)
+			v, err := New(filepath.Join(t.TempDir(), "vault"), c.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !c.check(v) {
+				t.Fatalf("%s didn't reach the vault", c.name)
+			}
+		})
+	}
+}
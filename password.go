@@ -0,0 +1,118 @@
+package uggsec
+
+import (
+	crand "crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"io"
+)
+
+// letterRunes is the alphabet generated passwords are drawn from. It
+// is limited to letters and digits so passwords survive being pasted
+// into shells and config files, and intentionally leaves out the
+// digit 0 so it can't be misread as the letter O.
+var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ123456789")
+
+// NewVaultPassword returns a random password that can be used for
+// interacting with vaults. Passwords no longer have to be exactly
+// keySize bytes since the encryption key is derived from them, but
+// this is still a useful helper function when doing things like
+// setting the contents of ENV vars on systems that don't support keyring.
+// It panics if the system's secure random number generator fails,
+// use NewVaultPasswordN to get an error instead.
+func NewVaultPassword() string {
+	password, err := NewVaultPasswordN(keySize)
+	if err != nil {
+		panic(err)
+	}
+	return password
+}
+
+// NewVaultPasswordN returns a random password made up of n runes
+// from the same alphabet as NewVaultPassword. It returns an error
+// if n is not positive rather than an empty password.
+func NewVaultPasswordN(n int) (string, error) {
+	if n <= 0 {
+		return "", fmt.Errorf("%w: %d", ErrInvalidPasswordLength, n)
+	}
+	return randStringRunes(crand.Reader, n, letterRunes)
+}
+
+// NewVaultPasswordWithAlphabet returns a random password made up of
+// n runes drawn uniformly from alphabet, for passwords that have to
+// satisfy composition rules such as requiring symbols. The alphabet
+// must be non-empty, hold at most 256 runes and contain no duplicates
+// since a repeated rune would be picked more often than the rest.
+func NewVaultPasswordWithAlphabet(n int, alphabet []rune) (string, error) {
+	if n <= 0 {
+		return "", fmt.Errorf("%w: %d", ErrInvalidPasswordLength, n)
+	}
+	if len(alphabet) == 0 || len(alphabet) > 256 {
+		return "", fmt.Errorf("%w: must have between 1 and 256 runes, got %d", ErrInvalidAlphabet, len(alphabet))
+	}
+	seen := make(map[rune]bool, len(alphabet))
+	for _, r := range alphabet {
+		if seen[r] {
+			return "", fmt.Errorf("%w: %q appears more than once", ErrInvalidAlphabet, r)
+		}
+		seen[r] = true
+	}
+	return randStringRunes(crand.Reader, n, alphabet)
+}
+
+// PasswordsEqual reports whether a and b are the same password,
+// taking the same time wherever they first differ so that code
+// checking a supplied password against a stored one doesn't leak
+// how much of it was right. It's meant for fixed-length secrets
+// such as those from NewVaultPassword: passwords of different
+// lengths compare unequal straight away, which reveals the length
+// of the stored one but nothing of its contents.
+func PasswordsEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// randReader returns the vault's source of randomness: crypto/rand
+// unless VaultInput.InsecureRandSource was set
+func (v *Vault) randReader() io.Reader {
+	if v.randSource != nil {
+		return v.randSource
+	}
+	return crand.Reader
+}
+
+// newPassword generates a password like NewVaultPassword but from
+// the vault's random source
+func (v *Vault) newPassword() (string, error) {
+	return randStringRunes(v.randReader(), keySize, letterRunes)
+}
+
+// randStringRunes draws n runes from alphabet using the random
+// source r, which should be crypto/rand since the results are used
+// as encryption passwords. Each random
+// byte is mapped to a rune with a modulo, but bytes at or above the
+// largest multiple of len(alphabet) that fits in a byte are thrown
+// away first. Without that rejection step the runes at the start of
+// the alphabet would come up slightly more often than the rest.
+// alphabet must hold between 1 and 256 runes.
+func randStringRunes(r io.Reader, n int, alphabet []rune) (string, error) {
+	size := len(alphabet)
+	limit := 256 - 256%size
+	b := make([]rune, 0, n)
+	buf := make([]byte, n)
+	for len(b) < n {
+		_, err := io.ReadFull(r, buf)
+		if err != nil {
+			return "", err
+		}
+		for _, r := range buf {
+			if int(r) >= limit {
+				continue
+			}
+			b = append(b, alphabet[int(r)%size])
+			if len(b) == n {
+				break
+			}
+		}
+	}
+	return string(b), nil
+}
@@ -0,0 +1,172 @@
+package uggsec
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"fmt"
+	mrand "math/rand"
+	"strings"
+	"testing"
+)
+
+func TestNewVaultPasswordUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		p := NewVaultPassword()
+		if len(p) != keySize {
+			t.Fatalf("NewVaultPassword() = %d characters, want %d", len(p), keySize)
+		}
+		if strings.Trim(p, string(letterRunes)) != "" {
+			t.Fatalf("NewVaultPassword() = %q, has characters outside letterRunes", p)
+		}
+		if seen[p] {
+			t.Fatalf("NewVaultPassword() repeated %q", p)
+		}
+		seen[p] = true
+	}
+}
+
+func TestNewVaultPasswordN(t *testing.T) {
+	if _, err := NewVaultPasswordN(0); err == nil {
+		t.Fatal("NewVaultPasswordN(0) succeeded")
+	}
+	p, err := NewVaultPasswordN(100)
+	if err != nil || len(p) != 100 {
+		t.Fatalf("NewVaultPasswordN(100) = %q, %v", p, err)
+	}
+}
+
+func TestRandStringRunesRejectsBiasedBytes(t *testing.T) {
+	// every byte value once: only those below the largest multiple
+	// of the alphabet size may be used and they then cover each rune
+	// equally often
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	limit := 256 - 256%len(letterRunes)
+	s, err := randStringRunes(bytes.NewReader(all), limit, letterRunes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	for _, r := range letterRunes {
+		if counts[r] != limit/len(letterRunes) {
+			t.Fatalf("%q drawn %d times, want %d", r, counts[r], limit/len(letterRunes))
+		}
+	}
+}
+
+func TestRandStringRunesUniform(t *testing.T) {
+	const perRune = 2000
+	n := perRune * len(letterRunes)
+	s, err := randStringRunes(crand.Reader, n, letterRunes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	chi2 := 0.0
+	for _, r := range letterRunes {
+		d := float64(counts[r] - perRune)
+		chi2 += d * d / perRune
+	}
+	// with 60 degrees of freedom a uniform source exceeds this about
+	// once in a million runs
+	if chi2 > 130 {
+		t.Fatalf("chi-squared %.1f over %d draws, distribution isn't uniform", chi2, n)
+	}
+}
+
+func TestNewVaultPasswordWithAlphabet(t *testing.T) {
+	if _, err := NewVaultPasswordWithAlphabet(8, []rune("aab")); err == nil {
+		t.Fatal("accepted an alphabet with a duplicate")
+	}
+	if _, err := NewVaultPasswordWithAlphabet(8, nil); err == nil {
+		t.Fatal("accepted an empty alphabet")
+	}
+	p, err := NewVaultPasswordWithAlphabet(50, []rune("!@#"))
+	if err != nil || len(p) != 50 || strings.Trim(p, "!@#") != "" {
+		t.Fatalf("NewVaultPasswordWithAlphabet() = %q, %v", p, err)
+	}
+}
+
+// recordLogger keeps every message logged through it
+func TestPasswordsEqual(t *testing.T) {
+	const p = "0123456789abcdefghijklmnopqrstuv"
+	for _, c := range []struct {
+		a, b string
+		want bool
+	}{
+		{p, p, true},
+		{"", "", true},
+		{p, p[:len(p)-1] + "?", false},
+		{p, "?" + p[1:], false},
+		{p, p[:len(p)-1], false},
+		{p, p + "x", false},
+		{p, "", false},
+	} {
+		if got := PasswordsEqual(c.a, c.b); got != c.want {
+			t.Errorf("PasswordsEqual(%q, %q) = %t, want %t", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+type recordLogger struct{ lines []string }
+
+func (l *recordLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestInsecureRandSourceReproducible(t *testing.T) {
+	seal := func() string {
+		v := newTestVault(t, &VaultInput{InsecureRandSource: mrand.New(mrand.NewSource(42))})
+		ciphertext, err := v.WriteDryRun("golden")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ciphertext
+	}
+	if seal() != seal() {
+		t.Fatal("the same seeded source sealed differently")
+	}
+	a, err := InitMemory(&VaultInput{InsecureRandSource: mrand.New(mrand.NewSource(42))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := InitMemory(&VaultInput{InsecureRandSource: mrand.New(mrand.NewSource(42))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a.password) != string(b.password) {
+		t.Fatal("the same seeded source generated different passwords")
+	}
+}
+
+func TestInsecureRandSourceIsOptIn(t *testing.T) {
+	l := &recordLogger{}
+	newTestVault(t, &VaultInput{InsecureRandSource: mrand.New(mrand.NewSource(1)), Logger: l})
+	if !strings.Contains(strings.Join(l.lines, "\n"), "InsecureRandSource") {
+		t.Fatalf("no warning logged for InsecureRandSource, got %q", l.lines)
+	}
+	v := newTestVault(t, nil)
+	if v.randReader() != crand.Reader {
+		t.Fatal("vault without InsecureRandSource doesn't use crypto/rand")
+	}
+	first, err := v.WriteDryRun("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := v.WriteDryRun("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("default source sealed the same contents identically")
+	}
+}
@@ -0,0 +1,63 @@
+package uggsec
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zalando/go-keyring"
+	"os"
+	"time"
+)
+
+// rotatedAtSuffix is appended to a keyring vault's user label to
+// get the label of the companion entry recording when its password
+// was last set
+const rotatedAtSuffix = ".uggsec-rotated-at"
+
+// PasswordAge returns how long ago the vault's password was last
+// changed, for tools that warn about stale keys. Keyring vaults
+// record the time in a companion keyring entry whenever InitKeyring
+// creates a password or Rotate replaces it. PasswordFile vaults
+// report the age of the password file. Keyring passwords set before
+// this was recorded give ErrPasswordAgeUnknown, other mechanisms
+// ErrNotSupported since the password lives outside the vault's
+// control.
+func (v *Vault) PasswordAge() (age time.Duration, err error) {
+	defer wrapOp("password age", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	switch {
+	case v.keyring:
+		s, err := v.ring.Get(v.service, v.user+rotatedAtSuffix)
+		if errors.Is(err, keyring.ErrNotFound) {
+			return 0, fmt.Errorf("%w: no rotation time for service %q user %q", ErrPasswordAgeUnknown, v.service, v.user)
+		}
+		if err != nil {
+			return 0, classifyKeyringError(err)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrPasswordAgeUnknown, err)
+		}
+		return v.nowFunc().Sub(t), nil
+	case v.passwordFile != "":
+		info, err := os.Stat(v.passwordFile)
+		if err != nil {
+			return 0, err
+		}
+		return v.nowFunc().Sub(info.ModTime()), nil
+	}
+	return 0, fmt.Errorf("%w: password age isn't tracked for the %s mechanism", ErrNotSupported, v.Mechanism())
+}
+
+// recordRotation stores the current time as the moment a keyring
+// vault's password was set. The password itself is already in place
+// by then so a failure here is only logged.
+func (v *Vault) recordRotation() {
+	if !v.keyring {
+		return
+	}
+	now := v.nowFunc().UTC().Format(time.RFC3339)
+	if err := v.ring.Set(v.service, v.user+rotatedAtSuffix, now); err != nil {
+		v.log("Warn", "recordRotation(), unable to store password rotation time", "error", err.Error())
+	}
+}
@@ -0,0 +1,62 @@
+package uggsec
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPasswordAgeKeyring(t *testing.T) {
+	useKeyring(t, mapRing{})
+	clock := newFakeClock()
+	v, err := InitKeyring(&VaultInput{
+		Filename:  filepath.Join(t.TempDir(), "vault"),
+		Service:   "svc",
+		User:      "usr",
+		KDFParams: KDFParams{N: minKDFN, R: 1, P: 1},
+		Clock:     clock.Now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(90 * 24 * time.Hour)
+	age, err := v.PasswordAge()
+	if err != nil || age != 90*24*time.Hour {
+		t.Fatalf("PasswordAge() = %v, %v, want 90 days", age, err)
+	}
+	if err := v.Write("x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Rotate(""); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if age, err := v.PasswordAge(); err != nil || age != time.Hour {
+		t.Fatalf("PasswordAge() after Rotate = %v, %v, want an hour", age, err)
+	}
+}
+
+func TestPasswordAgeUnknown(t *testing.T) {
+	ring := mapRing{"svc/usr": NewVaultPassword()}
+	useKeyring(t, ring)
+	v, err := InitKeyring(&VaultInput{
+		Filename:  filepath.Join(t.TempDir(), "vault"),
+		Service:   "svc",
+		User:      "usr",
+		KDFParams: KDFParams{N: minKDFN, R: 1, P: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.PasswordAge(); !errors.Is(err, ErrPasswordAgeUnknown) {
+		t.Fatalf("PasswordAge() = %v, want ErrPasswordAgeUnknown", err)
+	}
+}
+
+func TestPasswordAgeNotSupported(t *testing.T) {
+	v := newTestVault(t, nil)
+	if _, err := v.PasswordAge(); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("PasswordAge() = %v, want ErrNotSupported", err)
+	}
+}
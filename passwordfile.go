@@ -0,0 +1,72 @@
+package uggsec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// InitPasswordFile initializes a new or existing vault whose
+// password is read from the file named by PasswordFile, the way
+// Docker and Kubernetes mount secrets (for example
+// /run/secrets/vault). The file is read on every use so a secret
+// that is swapped out takes effect without a restart. A single
+// trailing newline is ignored. The same length rules as for ENV
+// vars apply and a warning is logged when the file is readable by
+// everyone. Rotate rewrites the file, which fails on read-only
+// mounts.
+func InitPasswordFile(i *VaultInput) (*Vault, error) {
+	v := newVault(i)
+	v.passwordFile = i.PasswordFile
+	if v.passwordFile == "" {
+		return v, fmt.Errorf("%w: PasswordFile is not set", ErrMissingPassword)
+	}
+	if info, err := os.Stat(v.passwordFile); err == nil && info.Mode().Perm()&0004 != 0 {
+		v.log("Warn", "InitPasswordFile(), password file is world-readable",
+			"file", v.passwordFile, "mode", info.Mode().String())
+	}
+	password, err := v.getPassword()
+	if err != nil {
+		return v, err
+	}
+	wipe(password)
+	_, err = v.loadFromDisk(context.Background())
+	if err != nil {
+		v.log("Debug", "InitPasswordFile(), error loading file from disk", "error", err.Error())
+		if errors.Is(err, ErrVaultNotFound) {
+			err = nil
+			if i.CreateIfMissing {
+				v.log("Debug", "InitPasswordFile(), attempting to create blank file")
+				err = v.Init()
+			}
+		}
+	}
+	return v, err
+}
+
+// filePassword reads a password from the file at path with the
+// checks described on getPasswordEnv, ignoring one trailing newline
+func filePassword(path string) (password []byte, err error) {
+	password, err = ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: password file %s does not exist", ErrMissingPassword, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSuffix(password, []byte("\n"))
+	trimmed = bytes.TrimSuffix(trimmed, []byte("\r"))
+	if len(trimmed) == 0 {
+		wipe(password)
+		return nil, fmt.Errorf("%w: password file %s is empty", ErrMissingPassword, path)
+	}
+	if len(trimmed) < minPasswordLength {
+		wipe(password)
+		return nil, fmt.Errorf("%w: password file %s holds %d characters, need at least %d (see NewVaultPassword)",
+			ErrWeakPassword, path, len(trimmed), minPasswordLength)
+	}
+	return trimmed, nil
+}
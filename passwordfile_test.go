@@ -0,0 +1,82 @@
+package uggsec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePasswordFile stores contents in a password file in a fresh
+// directory and returns its path
+func writePasswordFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func initPasswordFileVault(t *testing.T, filename, passwordFile string) *Vault {
+	t.Helper()
+	v, err := InitPasswordFile(&VaultInput{Filename: filename, PasswordFile: passwordFile,
+		KDFParams: KDFParams{N: minKDFN, R: 1, P: 1}})
+	if err != nil {
+		t.Fatalf("InitPasswordFile: %v", err)
+	}
+	return v
+}
+
+func TestPasswordFileRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "vault")
+	v := initPasswordFileVault(t, filename, writePasswordFile(t, testPassword+"\n"))
+	if m := v.Mechanism(); m != MechanismFile {
+		t.Fatalf("Mechanism() = %s, want %s", m, MechanismFile)
+	}
+	if err := v.Write("from a mounted secret"); err != nil {
+		t.Fatal(err)
+	}
+	// the same password without the newline, or with a CRLF, opens
+	// the same vault
+	for _, contents := range []string{testPassword, testPassword + "\r\n"} {
+		other := initPasswordFileVault(t, filename, writePasswordFile(t, contents))
+		if got, err := other.Read(); err != nil || got != "from a mounted secret" {
+			t.Fatalf("Read() with password file %q = %q, %v", contents, got, err)
+		}
+	}
+}
+
+func TestFilePasswordTrimsOneNewline(t *testing.T) {
+	for contents, want := range map[string]string{
+		testPassword:          testPassword,
+		testPassword + "\n":   testPassword,
+		testPassword + "\r\n": testPassword,
+		testPassword + "\n\n": testPassword + "\n",
+	} {
+		got, err := filePassword(writePasswordFile(t, contents))
+		if err != nil || string(got) != want {
+			t.Errorf("filePassword(%q) = %q, %v, want %q", contents, got, err, want)
+		}
+	}
+}
+
+func TestPasswordFileProblems(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "vault")
+	for name, c := range map[string]struct {
+		path string
+		want error
+	}{
+		"missing": {filepath.Join(t.TempDir(), "nothing here"), ErrMissingPassword},
+		"empty":   {writePasswordFile(t, "\n"), ErrMissingPassword},
+		"short":   {writePasswordFile(t, "short\n"), ErrWeakPassword},
+	} {
+		_, err := InitPasswordFile(&VaultInput{Filename: filename, PasswordFile: c.path})
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: InitPasswordFile() = %v, want %v", name, err, c.want)
+		}
+	}
+	if _, err := InitPasswordFile(&VaultInput{Filename: filename}); !errors.Is(err, ErrMissingPassword) {
+		t.Errorf("InitPasswordFile() without PasswordFile = %v, want ErrMissingPassword", err)
+	}
+}
@@ -0,0 +1,56 @@
+package uggsec
+
+import (
+	"bufio"
+	"fmt"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strings"
+)
+
+// PromptPassword writes prompt to stderr and reads a password from
+// stdin without echoing it, ready to be used as VaultInput.Password.
+// When stdin isn't a terminal, such as when a password is piped in,
+// a single line is read instead so scripts work too. The trailing
+// newline is not part of the returned password.
+func PromptPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		b, err := term.ReadPassword(fd)
+		// the user's enter key wasn't echoed either
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	return readPasswordLine(os.Stdin)
+}
+
+// ReadPasswordStdin reads a password piped to stdin, for scripts
+// and CI jobs that run something like echo "$PASS" | mytool. Only the
+// first line is read and its line ending dropped. It never waits on
+// a person: when stdin is a terminal ErrMissingPassword is returned
+// straight away, use PromptPassword to ask interactively instead.
+// Empty input is also reported as ErrMissingPassword.
+func ReadPasswordStdin() (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("%w: stdin is a terminal, not a pipe", ErrMissingPassword)
+	}
+	password, err := readPasswordLine(os.Stdin)
+	if err == io.EOF || err == nil && password == "" {
+		return "", fmt.Errorf("%w: nothing was piped to stdin", ErrMissingPassword)
+	}
+	return password, err
+}
+
+// readPasswordLine reads one line from r, dropping the line ending
+func readPasswordLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,49 @@
+package uggsec
+
+import (
+	"sync"
+	"time"
+)
+
+// passwordCache holds a copy of a vault's primary password for
+// VaultInput.PasswordCacheTTL after it was fetched. Only callers
+// holding the vault's lock fill it, but it keeps a lock of its own
+// so that reading or clearing it never depends on that.
+type passwordCache struct {
+	mu       sync.Mutex
+	password []byte
+	expires  time.Time
+}
+
+// get returns a copy of the cached password if there is one that
+// hasn't expired by now, wiping it if it has
+func (c *passwordCache) get(now time.Time) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.password == nil {
+		return nil, false
+	}
+	if !now.Before(c.expires) {
+		wipe(c.password)
+		c.password = nil
+		return nil, false
+	}
+	return copyBytes(c.password), true
+}
+
+// put caches a copy of password until expires
+func (c *passwordCache) put(password []byte, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wipe(c.password)
+	c.password = copyBytes(password)
+	c.expires = expires
+}
+
+// clear wipes and forgets any cached password
+func (c *passwordCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wipe(c.password)
+	c.password = nil
+}
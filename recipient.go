@@ -0,0 +1,111 @@
+package uggsec
+
+import (
+	crand "crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+	"time"
+)
+
+// Recipient mode encrypts to someone else's public key instead of
+// the vault's password, for one-way submission where the writer
+// shouldn't be able to read back what it wrote, such as a collector
+// encrypting to an operator's key. Contents are sealed in an
+// anonymous NaCl box with a fresh ephemeral key each time, so the
+// writer keeps nothing that could open them. The vault's password
+// mechanism isn't used for these writes at all, only the holder of
+// the matching private key can read the vault with
+// ReadWithPrivateKey while Read returns ErrNotSupported.
+
+// GenerateRecipientKey returns a new X25519 key pair for use with
+// WriteToRecipient and ReadWithPrivateKey. The public key can be
+// handed to writers freely, the private key must be kept secret.
+func GenerateRecipientKey() (publicKey, privateKey *[32]byte, err error) {
+	return box.GenerateKey(crand.Reader)
+}
+
+// WriteToRecipient encrypts contents to the recipient's public key
+// and writes them as the vault's contents, atomically like Write.
+// Compress and AssociatedData apply as usual, WriteWithTTL style
+// expiry isn't available.
+func (v *Vault) WriteToRecipient(contents []byte, recipient *[32]byte) (err error) {
+	defer wrapOp("write", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.observer != nil {
+		defer v.observeWrite(time.Now(), &err)
+	}
+	if err := v.writable(); err != nil {
+		return err
+	}
+	h, b, err := v.wrapPlainText(newHeader(algX25519Box), contents)
+	if err != nil {
+		return err
+	}
+	// the box has no associated data of its own, so the header and
+	// ad are sealed in front of the contents and checked on open
+	msg := append(h.aad(v.associatedData), b...)
+	defer wipe(msg)
+	sealed, err := box.SealAnonymous(nil, msg, recipient, v.randReader())
+	if err != nil {
+		return err
+	}
+	v.log("Debug", "WriteToRecipient(), writing file...")
+	return v.storeEncrypted(h.String() + h.encodePayload(sealed))
+}
+
+// ReadWithPrivateKey decrypts contents written by WriteToRecipient
+// using the recipient's private key. ErrTampered is returned if the
+// key is the wrong one or the file was modified, and ErrNotSupported
+// if the vault wasn't written by WriteToRecipient.
+func (v *Vault) ReadWithPrivateKey(privateKey *[32]byte) (contents []byte, err error) {
+	defer wrapOp("read", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.observer != nil {
+		defer v.observeRead(time.Now(), &err)
+	}
+	data, err := v.readRaw()
+	if err != nil {
+		return nil, err
+	}
+	h, payload, err := parseHeader(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if h.algorithm != algX25519Box {
+		return nil, fmt.Errorf("%w: vault wasn't written by WriteToRecipient", ErrNotSupported)
+	}
+	plainText, err := openBox(h, payload, privateKey, v.associatedData)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapPlainText(h, plainText, v.nowFunc())
+}
+
+// openBox opens the anonymous box in payload and checks that it was
+// sealed under h and ad
+func openBox(h header, payload string, privateKey *[32]byte, ad []byte) ([]byte, error) {
+	sealed, err := h.decodePayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := curve25519.X25519(privateKey[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	var publicKey [32]byte
+	copy(publicKey[:], pub)
+	msg, ok := box.OpenAnonymous(nil, sealed, &publicKey, privateKey)
+	if !ok {
+		return nil, ErrTampered
+	}
+	want := h.aad(ad)
+	if len(msg) < len(want) || subtle.ConstantTimeCompare(msg[:len(want)], want) != 1 {
+		wipe(msg)
+		return nil, ErrTampered
+	}
+	return msg[len(want):], nil
+}
@@ -0,0 +1,73 @@
+package uggsec
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// writeToNewRecipient writes contents to a fresh key pair through v
+// and returns the private key
+func writeToNewRecipient(t *testing.T, v *Vault, contents string) *[32]byte {
+	t.Helper()
+	public, private, err := GenerateRecipientKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.WriteToRecipient([]byte(contents), public); err != nil {
+		t.Fatal(err)
+	}
+	return private
+}
+
+func TestRecipientRoundTrip(t *testing.T) {
+	v := newTestVault(t, nil)
+	private := writeToNewRecipient(t, v, "for the operator")
+	got, err := v.ReadWithPrivateKey(private)
+	if err != nil || string(got) != "for the operator" {
+		t.Fatalf("ReadWithPrivateKey() = %q, %v", got, err)
+	}
+	if _, err := v.Read(); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Read() = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestRecipientWrongKey(t *testing.T) {
+	v := newTestVault(t, nil)
+	writeToNewRecipient(t, v, "for the operator")
+	_, other, err := GenerateRecipientKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.ReadWithPrivateKey(other); !errors.Is(err, ErrTampered) {
+		t.Fatalf("ReadWithPrivateKey(other key) = %v, want ErrTampered", err)
+	}
+}
+
+func TestRecipientTampered(t *testing.T) {
+	v := newTestVault(t, nil)
+	private := writeToNewRecipient(t, v, "for the operator")
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tamperAt(data, len(data)-8)
+	if err := os.WriteFile(v.filename, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.ReadWithPrivateKey(private); !errors.Is(err, ErrTampered) {
+		t.Fatalf("ReadWithPrivateKey() = %v, want ErrTampered", err)
+	}
+}
+
+func TestRecipientAssociatedDataMismatch(t *testing.T) {
+	v := newTestVault(t, &VaultInput{AssociatedData: []byte("tenant-1")})
+	other := newTestVault(t, &VaultInput{Filename: v.filename, AssociatedData: []byte("tenant-2")})
+	private := writeToNewRecipient(t, v, "for the operator")
+	if _, err := other.ReadWithPrivateKey(private); !errors.Is(err, ErrTampered) {
+		t.Fatalf("ReadWithPrivateKey() with other associated data = %v, want ErrTampered", err)
+	}
+	if got, err := v.ReadWithPrivateKey(private); err != nil || string(got) != "for the operator" {
+		t.Fatalf("ReadWithPrivateKey() = %q, %v", got, err)
+	}
+}
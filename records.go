@@ -0,0 +1,240 @@
+package uggsec
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// AppendRecord JSON encodes record and adds it to the end of the
+// vault's file as a line of its own, for audit trails and other logs
+// that only ever grow. Each line is sealed separately with a fresh
+// random nonce, so appending never rewrites or decrypts what's
+// already there and every line can be decrypted on its own with the
+// password. Lines share the scrypt salt of the first record when the
+// vault's settings haven't changed since, which lets ReadRecords
+// derive the key once rather than per line. The random 96 bit nonces
+// keep this safe for billions of records.
+//
+// A vault used for records holds a line per record rather than a
+// single ciphertext, so read it with ReadRecords only. RawBinary is
+// ignored since binary ciphertext could contain line breaks.
+func (v *Vault) AppendRecord(record interface{}) (err error) {
+	defer wrapOp("append", v.filename, &err)
+	b, err := json.Marshal(record)
+	if err != nil {
+		return &JSONError{Op: "encoding", Err: err}
+	}
+	defer wipe(b)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.observer != nil {
+		defer v.observeWrite(time.Now(), &err)
+	}
+	if err := v.writable(); err != nil {
+		return err
+	}
+	h, err := v.applySettings(newHeader(algAES256GCM))
+	if err != nil {
+		return err
+	}
+	h.flags &^= flagRawBinary
+	password, err := v.getPassword()
+	if err != nil {
+		return err
+	}
+	defer wipe(password)
+	unlock, err := v.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	salt, err := v.recordSalt(h)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(h, password, salt)
+	if err != nil {
+		return err
+	}
+	line, err := sealAEAD(v.randReader(), h, aead, salt, b, v.associatedData)
+	if err != nil {
+		return err
+	}
+	line += "\n"
+	if v.needsNewline() {
+		// the file holds something other than records, such as the
+		// empty vault Init writes, which has no line break after it
+		line = "\n" + line
+	}
+	v.log("Debug", "AppendRecord(), appending record...")
+	return v.appendRaw([]byte(line))
+}
+
+// ReadRecords decrypts every record added with AppendRecord and
+// decodes them, in the order they were appended, into the slice
+// pointed to by into following the rules of json.Unmarshal. A record
+// that fails authentication stops the read with an error naming its
+// line, wrapping ErrTampered. A vault with no records, including one
+// whose file doesn't exist yet, leaves into an empty slice.
+func (v *Vault) ReadRecords(into interface{}) (err error) {
+	defer wrapOp("read", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.observer != nil {
+		defer v.observeRead(time.Now(), &err)
+	}
+	data, err := v.readRaw()
+	if errors.Is(err, ErrVaultNotFound) {
+		// nothing appended yet
+		v.log("Debug", "ReadRecords(), vault doesn't exist yet", "file", v.filename)
+		err = json.Unmarshal([]byte("[]"), into)
+		if err != nil {
+			return &JSONError{Op: "decoding", Err: err}
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	password, err := v.getPassword()
+	if err != nil {
+		return err
+	}
+	defer wipe(password)
+	v.log("Debug", "ReadRecords(), decrypting records...")
+	ciphers := make(map[string]cipher.AEAD)
+	buf := []byte{'['}
+	defer func() { wipe(buf) }()
+	for n, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		b, err := v.openRecord(string(line), password, ciphers)
+		if err != nil {
+			return fmt.Errorf("record on line %d: %w", n+1, err)
+		}
+		if len(b) == 0 {
+			// an empty vault, as written by Init
+			continue
+		}
+		if !json.Valid(b) {
+			wipe(b)
+			return &JSONError{Op: "decoding", Err: fmt.Errorf("record on line %d is not valid JSON", n+1)}
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, b...)
+		wipe(b)
+	}
+	buf = append(buf, ']')
+	err = json.Unmarshal(buf, into)
+	if err != nil {
+		return &JSONError{Op: "decoding", Err: err}
+	}
+	return nil
+}
+
+// openRecord decrypts one line written by AppendRecord. ciphers
+// holds the AEAD for every header and salt seen so far so lines
+// sharing a salt only derive the key once.
+func (v *Vault) openRecord(line string, password []byte, ciphers map[string]cipher.AEAD) ([]byte, error) {
+	h, payload, err := parseHeader(line)
+	if err != nil {
+		return nil, err
+	}
+	if contentKeySize(h.algorithm) == 0 {
+		return nil, fmt.Errorf("%w: id %02x is not a record", ErrUnsupportedAlgorithm, h.algorithm)
+	}
+	data, err := h.decodePayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < saltSize {
+		return nil, ErrTampered
+	}
+	salt, data := data[:saltSize], data[saltSize:]
+	key := h.String() + string(salt)
+	aead, ok := ciphers[key]
+	if !ok {
+		aead, err = newAEAD(h, password, salt)
+		if err != nil {
+			return nil, err
+		}
+		ciphers[key] = aead
+	}
+	plainText, err := openAEAD(h, aead, data, v.associatedData)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapPlainText(h, plainText, v.nowFunc())
+}
+
+// recordSalt returns the salt of the vault's first record if it was
+// written under the same header as h, or a new random salt if not
+func (v *Vault) recordSalt(h header) ([]byte, error) {
+	if first, err := v.firstLine(); err == nil {
+		fh, payload, err := parseHeader(first)
+		if err == nil && fh == h {
+			data, err := fh.decodePayload(payload)
+			if err == nil && len(data) >= saltSize {
+				return data[:saltSize], nil
+			}
+		}
+	}
+	salt := make([]byte, saltSize)
+	_, err := io.ReadFull(v.randReader(), salt)
+	return salt, err
+}
+
+// needsNewline reports whether the vault's stored contents are
+// non-empty and don't end in a line break
+func (v *Vault) needsNewline() bool {
+	if !v.usesOSFiles() {
+		return len(v.memData) > 0 && v.memData[len(v.memData)-1] != '\n'
+	}
+	f, err := fileBackend.OpenFile(v.filename, os.O_RDONLY, 0)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return false
+	}
+	last := make([]byte, 1)
+	_, err = f.ReadAt(last, info.Size()-1)
+	return err == nil && last[0] != '\n'
+}
+
+// firstLine returns the first line of the vault's stored contents
+// without reading the rest of the file
+func (v *Vault) firstLine() (string, error) {
+	var r io.Reader
+	if v.usesOSFiles() {
+		f, err := fileBackend.OpenFile(v.filename, os.O_RDONLY, 0)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		r = f
+	} else {
+		data, err := v.readRaw()
+		if err != nil {
+			return "", err
+		}
+		r = bytes.NewReader(data)
+	}
+	line, err := bufio.NewReader(r).ReadSlice('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return string(bytes.TrimSuffix(line, []byte{'\n'})), nil
+}
@@ -0,0 +1,65 @@
+package uggsec
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+type testRecord struct {
+	Seq  int
+	Note string
+}
+
+func TestAppendAndReadRecords(t *testing.T) {
+	v := newTestVault(t, nil)
+	for i := 1; i <= 3; i++ {
+		if err := v.AppendRecord(testRecord{Seq: i, Note: "entry"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []testRecord
+	if err := v.ReadRecords(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].Seq != 1 || got[2].Seq != 3 {
+		t.Fatalf("ReadRecords() = %+v", got)
+	}
+}
+
+func TestReadRecordsMissingVault(t *testing.T) {
+	v := newTestVault(t, nil)
+	got := []testRecord{{Seq: 9}}
+	if err := v.ReadRecords(&got); err != nil {
+		t.Fatalf("ReadRecords() = %v, want no error for a missing vault", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Fatalf("ReadRecords() left %#v, want an empty slice", got)
+	}
+}
+
+func TestReadRecordsTampered(t *testing.T) {
+	v := newTestVault(t, nil)
+	for i := 1; i <= 2; i++ {
+		if err := v.AppendRecord(testRecord{Seq: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	last := []byte(lines[1])
+	tamperAt(last, len(last)-3)
+	lines[1] = string(last)
+	if err := os.WriteFile(v.filename, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var got []testRecord
+	err = v.ReadRecords(&got)
+	if !errors.Is(err, ErrTampered) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("ReadRecords() = %v, want ErrTampered naming line 2", err)
+	}
+}
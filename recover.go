@@ -0,0 +1,82 @@
+package uggsec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TryRecover is a best effort recovery tool for vault files that
+// were truncated by a crash, which could happen before writes were
+// made atomic. It returns the contents of the first of these that
+// decrypts: the vault's file, the backup kept by KeepBackup, then
+// any temp files left next to the vault by an interrupted write,
+// newest first. Nothing is modified, write the result back to
+// repair the vault. Use TryRecoverSource to find out which file the
+// contents came from, it's also logged.
+func (v *Vault) TryRecover() (contents string, err error) {
+	defer wrapOp("recover", v.filename, &err)
+	contents, _, err = v.TryRecoverSource()
+	return contents, err
+}
+
+// TryRecoverSource behaves like TryRecover and also returns the path
+// of the file the contents were recovered from. If there are no
+// candidate files at all ErrVaultNotFound is returned, otherwise the
+// error from the first candidate, with every candidate's listed in
+// the message. In-memory and fs.FS vaults return ErrNotSupported.
+func (v *Vault) TryRecoverSource() (contents, source string, err error) {
+	defer wrapOp("recover", v.filename, &err)
+	if !v.usesOSFiles() {
+		return "", "", ErrNotSupported
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var first error
+	var problems []string
+	for _, path := range v.recoveryCandidates() {
+		data, err := fileBackend.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			var b []byte
+			b, err = v.open(context.Background(), string(data), v.associatedData)
+			if err == nil {
+				v.log("Info", "TryRecover(), recovered contents", "source", path)
+				return string(b), path, nil
+			}
+		}
+		v.log("Debug", "TryRecover(), candidate failed", "file", path, "error", err.Error())
+		if first == nil {
+			first = err
+		}
+		problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+	}
+	if first == nil {
+		return "", "", fmt.Errorf("%w: no vault, backup or temp file for %s", ErrVaultNotFound, v.filename)
+	}
+	return "", "", fmt.Errorf("%w (tried %s)", first, strings.Join(problems, "; "))
+}
+
+// recoveryCandidates lists the files TryRecover looks at in the
+// order it tries them
+func (v *Vault) recoveryCandidates() []string {
+	candidates := []string{v.filename, v.filename + backupSuffix}
+	dir, base := filepath.Split(v.filename)
+	temps, _ := filepath.Glob(filepath.Join(dir, "."+base+".tmp-*"))
+	modTimes := make(map[string]int64, len(temps))
+	for _, t := range temps {
+		if info, err := fileBackend.Stat(t); err == nil {
+			modTimes[t] = info.ModTime().UnixNano()
+		}
+	}
+	sort.SliceStable(temps, func(i, j int) bool {
+		return modTimes[temps[i]] > modTimes[temps[j]]
+	})
+	return append(candidates, temps...)
+}
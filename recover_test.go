@@ -0,0 +1,96 @@
+package uggsec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// truncateVault cuts v's file in half the way a crash mid-write
+// could have
+func truncateVault(t *testing.T, v *Vault) {
+	t.Helper()
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(v.filename, data[:len(data)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// leaveTempFile writes data to a temp file next to v's file, as an
+// interrupted write would, last modified at mtime
+func leaveTempFile(t *testing.T, v *Vault, suffix, data string, mtime time.Time) string {
+	t.Helper()
+	dir, base := filepath.Split(v.filename)
+	path := filepath.Join(dir, "."+base+".tmp-"+suffix)
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTryRecoverFromBackup(t *testing.T) {
+	v := newTestVault(t, &VaultInput{KeepBackup: true})
+	for _, contents := range []string{"first", "second"} {
+		if err := v.Write(contents); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, source, err := v.TryRecoverSource(); err != nil || got != "second" || source != v.filename {
+		t.Fatalf("TryRecoverSource() of a healthy vault = %q, %q, %v", got, source, err)
+	}
+	truncateVault(t, v)
+	got, source, err := v.TryRecoverSource()
+	if err != nil || got != "first" || source != v.filename+backupSuffix {
+		t.Fatalf("TryRecoverSource() = %q, %q, %v, want the backup", got, source, err)
+	}
+}
+
+func TestTryRecoverNewestTempFile(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("current"); err != nil {
+		t.Fatal(err)
+	}
+	older, err := v.WriteDryRun("older")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer, err := v.WriteDryRun("newer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncateVault(t, v)
+	now := time.Now()
+	leaveTempFile(t, v, "1", older, now.Add(-2*time.Hour))
+	want := leaveTempFile(t, v, "2", newer, now.Add(-time.Hour))
+	// the newest candidate doesn't authenticate and is passed over
+	leaveTempFile(t, v, "3", newer[:len(newer)/2], now)
+	got, source, err := v.TryRecoverSource()
+	if err != nil || got != "newer" || source != want {
+		t.Fatalf("TryRecoverSource() = %q, %q, %v, want %q from %s", got, source, err, "newer", want)
+	}
+	if got, err := v.TryRecover(); err != nil || got != "newer" {
+		t.Fatalf("TryRecover() = %q, %v", got, err)
+	}
+}
+
+func TestTryRecoverNothingToRecover(t *testing.T) {
+	v := newTestVault(t, nil)
+	if _, err := v.TryRecover(); !errors.Is(err, ErrVaultNotFound) {
+		t.Fatalf("TryRecover() = %v, want ErrVaultNotFound", err)
+	}
+	if err := v.Write("current"); err != nil {
+		t.Fatal(err)
+	}
+	truncateVault(t, v)
+	if _, err := v.TryRecover(); err == nil || errors.Is(err, ErrVaultNotFound) {
+		t.Fatalf("TryRecover() with only a truncated file = %v, want its failure", err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/rendicott/uggsec"
@@ -15,6 +16,8 @@ var (
 	encryptFlag    = flag.Bool("encrypt", false, "whether to encrypt string and write to disk")
 	decryptFlag    = flag.Bool("decrypt", false, "whether to decrypt file print screen")
 	newPassword    = flag.Bool("new-password", false, "creates a new password and repeats back to STDOUT. Usefull for setting ENV var")
+	verbose        = flag.Bool("verbose", false, "log uggsec's decisions while initializing the vault")
+	prompt         = flag.Bool("prompt", false, "prompt for the encryption/decryption password instead of using keyring or ENV var")
 )
 
 func main() {
@@ -27,15 +30,30 @@ func main() {
 		Filename: *filename,
 		Service:  "ugglyc",
 		User:     "browser",
-		//PasswordEnvVar: "", // must contain 32 byte password
+		// used if the keyring is unavailable, defaults to UGGSECP
+		PasswordEnvVar: *passwordEnvVar,
+		// start with an empty vault the first time the sample runs
+		CreateIfMissing: true,
+	}
+	if *verbose {
+		params.Logger = log.Default()
+	}
+	if *prompt {
+		password, err := uggsec.PromptPassword("password: ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		params.Password = uggsec.Secret(password)
 	}
 	vault, err := uggsec.InitSmart(&params)
+	if errors.Is(err, uggsec.ErrKeyringLocked) || errors.Is(err, uggsec.ErrKeyringPermission) {
+		log.Fatalf("keyring refused access, unlock it and try again: %v", err)
+	}
 	if err != nil {
-		log.Printf("error initiating keyring: %v", err)
-		log.Print("using ENV var instead")
-		// must contain 32 byte string. Defaults to UGGSECP if left blank
-		params.PasswordEnvVar = *passwordEnvVar
-		vault, err = uggsec.InitSmart(&params)
+		log.Fatalf("error initiating vault: %v", err)
+	}
+	if *verbose {
+		log.Printf("password mechanism: %s", vault.Mechanism())
 	}
 	if *encryptFlag {
 		err = vault.Write(*message)
@@ -0,0 +1,53 @@
+package uggsec
+
+import (
+	"context"
+	"runtime"
+)
+
+// SecretBytes holds decrypted contents in a byte slice that can be
+// wiped once it is no longer needed, unlike a string which stays in
+// memory until the garbage collector gets around to it and may turn
+// up in core dumps. Like Secret it formats as "[redacted]".
+type SecretBytes []byte
+
+// String implements fmt.Stringer without revealing the secret.
+func (s SecretBytes) String() string {
+	return "[redacted]"
+}
+
+// GoString implements fmt.GoStringer so %#v doesn't reveal the
+// secret either.
+func (s SecretBytes) GoString() string {
+	return "uggsec.SecretBytes([redacted])"
+}
+
+// Destroy overwrites the secret with zeros. The slice must not be
+// used for anything else afterwards.
+func (s SecretBytes) Destroy() {
+	wipe(s)
+}
+
+// ReadSecret behaves like ReadBytes but returns the contents as
+// SecretBytes so the caller can Destroy them when done. Without
+// CacheReads the returned slice is the only copy of the plaintext
+// this package keeps. With it the vault holds another copy for later
+// reads until the file changes or a write drops it, so leave
+// CacheReads off for contents that should only live in the slice.
+func (v *Vault) ReadSecret() (secret SecretBytes, err error) {
+	defer wrapOp("read", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	b, err := v.loadFromDisk(context.Background())
+	return SecretBytes(b), err
+}
+
+// wipe zeroes b, used for derived keys and other key material once
+// it has been handed to the cipher that needs it
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	// keep the compiler from treating the loop as a dead store
+	runtime.KeepAlive(b)
+}
@@ -0,0 +1,28 @@
+package uggsec
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestReadSecret(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("hunter2hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := v.ReadSecret()
+	if err != nil || string(secret) != "hunter2hunter2" {
+		t.Fatalf("ReadSecret() = %q, %v", []byte(secret), err)
+	}
+	for _, format := range []string{"%v", "%s", "%#v"} {
+		if got := fmt.Sprintf(format, secret); got != "[redacted]" && got != "uggsec.SecretBytes([redacted])" {
+			t.Errorf("%s formats as %q", format, got)
+		}
+	}
+	secret.Destroy()
+	for _, c := range secret {
+		if c != 0 {
+			t.Fatalf("Destroy() left %q", []byte(secret))
+		}
+	}
+}
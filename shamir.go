@@ -0,0 +1,195 @@
+package uggsec
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Shares produced by SplitPassword are plain text of the form
+// "threshold-x-hex", where x is the share's point on the secret
+// polynomial and hex the polynomial's value there for every byte of
+// the secret. The secret is the password prefixed with a short
+// SHA-256 checksum so that CombinePassword can tell a correct
+// reconstruction from garbage produced by bad or mismatched shares.
+
+// shareChecksumSize is how many bytes of checksum are prepended to
+// the password before splitting
+const shareChecksumSize = 4
+
+// SplitPassword splits password into parts shares using Shamir's
+// secret sharing so that any threshold of them recover it with
+// CombinePassword while fewer reveal nothing about it. This lets a
+// vault password be handed out among several people for break-glass
+// recovery without any one of them holding it. threshold must be at
+// least 2 and no more than parts, which can be at most 255.
+func SplitPassword(password string, parts, threshold int) ([]string, error) {
+	if password == "" {
+		return nil, ErrMissingPassword
+	}
+	if threshold < 2 || threshold > parts || parts > 255 {
+		return nil, fmt.Errorf("%w: need 2 <= threshold <= parts <= 255, got threshold %d parts %d",
+			ErrInvalidShares, threshold, parts)
+	}
+	secret := shareSecret(password)
+	ys := make([][]byte, parts)
+	for i := range ys {
+		ys[i] = make([]byte, len(secret))
+	}
+	coeffs := make([]byte, threshold)
+	for j, s := range secret {
+		// a random polynomial of degree threshold-1 through (0, s)
+		_, err := io.ReadFull(crand.Reader, coeffs[1:])
+		if err != nil {
+			return nil, err
+		}
+		coeffs[0] = s
+		for i := range ys {
+			ys[i][j] = gfEval(coeffs, byte(i+1))
+		}
+	}
+	wipe(coeffs)
+	shares := make([]string, parts)
+	for i, y := range ys {
+		shares[i] = fmt.Sprintf("%d-%d-%s", threshold, i+1, hex.EncodeToString(y))
+	}
+	return shares, nil
+}
+
+// CombinePassword recovers a password from shares made by
+// SplitPassword. At least as many shares as the threshold they were
+// made with are needed, extra ones are ignored. ErrInvalidShares is
+// returned when there are too few shares, they are malformed or come
+// from different splits.
+func CombinePassword(shares []string) (string, error) {
+	if len(shares) == 0 {
+		return "", fmt.Errorf("%w: no shares given", ErrInvalidShares)
+	}
+	threshold := 0
+	var xs []byte
+	var ys [][]byte
+	seen := make(map[byte]bool)
+	for _, share := range shares {
+		t, x, y, err := parseShare(share)
+		if err != nil {
+			return "", err
+		}
+		if threshold == 0 {
+			threshold = t
+		}
+		if t != threshold || (len(ys) > 0 && len(y) != len(ys[0])) {
+			return "", fmt.Errorf("%w: shares come from different splits", ErrInvalidShares)
+		}
+		if seen[x] {
+			continue
+		}
+		seen[x] = true
+		xs = append(xs, x)
+		ys = append(ys, y)
+	}
+	if len(xs) < threshold {
+		return "", fmt.Errorf("%w: have %d distinct shares, need %d", ErrInvalidShares, len(xs), threshold)
+	}
+	xs, ys = xs[:threshold], ys[:threshold]
+	secret := make([]byte, len(ys[0]))
+	for j := range secret {
+		secret[j] = gfInterpolateZero(xs, ys, j)
+	}
+	password := string(secret[shareChecksumSize:])
+	if !bytes.Equal(secret[:shareChecksumSize], shareSecret(password)[:shareChecksumSize]) {
+		return "", fmt.Errorf("%w: shares don't combine to a valid password", ErrInvalidShares)
+	}
+	return password, nil
+}
+
+func shareSecret(password string) []byte {
+	sum := sha256.Sum256([]byte(password))
+	return append(sum[:shareChecksumSize:shareChecksumSize], password...)
+}
+
+func parseShare(share string) (threshold int, x byte, y []byte, err error) {
+	fields := strings.Split(strings.TrimSpace(share), "-")
+	if len(fields) != 3 {
+		return 0, 0, nil, fmt.Errorf("%w: malformed share", ErrInvalidShares)
+	}
+	threshold, err = strconv.Atoi(fields[0])
+	if err != nil || threshold < 2 || threshold > 255 {
+		return 0, 0, nil, fmt.Errorf("%w: malformed share threshold", ErrInvalidShares)
+	}
+	xi, err := strconv.Atoi(fields[1])
+	if err != nil || xi < 1 || xi > 255 {
+		return 0, 0, nil, fmt.Errorf("%w: malformed share index", ErrInvalidShares)
+	}
+	y, err = hex.DecodeString(fields[2])
+	if err != nil || len(y) <= shareChecksumSize {
+		return 0, 0, nil, fmt.Errorf("%w: malformed share value", ErrInvalidShares)
+	}
+	return threshold, byte(xi), y, nil
+}
+
+// GF(256) arithmetic using the AES polynomial x^8+x^4+x^3+x+1, with
+// log and exp tables built from the generator 3
+var gfExp, gfLog = gfTables()
+
+func gfTables() (exp [510]byte, lg [256]byte) {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		exp[i+255] = x
+		lg[x] = byte(i)
+		// multiply by the generator 3: x*2 xor x
+		x2 := x << 1
+		if x&0x80 != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	return exp, lg
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// gfEval evaluates the polynomial with the given coefficients, lowest
+// degree first, at x
+func gfEval(coeffs []byte, x byte) byte {
+	var y byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coeffs[i]
+	}
+	return y
+}
+
+// gfInterpolateZero returns the value at zero of the polynomial
+// through the points (xs[i], ys[i][j])
+func gfInterpolateZero(xs []byte, ys [][]byte, j int) byte {
+	var s byte
+	for i, xi := range xs {
+		// Lagrange basis at zero: product of xm / (xm - xi), and
+		// subtraction is xor in GF(256)
+		basis := byte(1)
+		for m, xm := range xs {
+			if m != i {
+				basis = gfMul(basis, gfDiv(xm, xm^xi))
+			}
+		}
+		s ^= gfMul(ys[i][j], basis)
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+package uggsec
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGFMulDiv(t *testing.T) {
+	// the worked example from FIPS-197 section 4.2
+	if got := gfMul(0x57, 0x83); got != 0xc1 {
+		t.Errorf("gfMul(0x57, 0x83) = %#x, want 0xc1", got)
+	}
+	for a := 0; a < 256; a++ {
+		if gfMul(byte(a), 0) != 0 || gfMul(0, byte(a)) != 0 {
+			t.Fatalf("gfMul(%#x, 0) != 0", a)
+		}
+		if got := gfMul(byte(a), 1); got != byte(a) {
+			t.Fatalf("gfMul(%#x, 1) = %#x", a, got)
+		}
+		for b := 1; b < 256; b++ {
+			p := gfMul(byte(a), byte(b))
+			if p != gfMul(byte(b), byte(a)) {
+				t.Fatalf("gfMul(%#x, %#x) isn't commutative", a, b)
+			}
+			if got := gfDiv(p, byte(b)); got != byte(a) {
+				t.Fatalf("gfDiv(gfMul(%#x, %#x), %#x) = %#x", a, b, b, got)
+			}
+		}
+	}
+}
+
+func TestGFInterpolateZero(t *testing.T) {
+	coeffs := []byte{0x2a, 0x11, 0xfe, 0x07}
+	xs := []byte{3, 9, 200, 255}
+	ys := make([][]byte, len(xs))
+	for i, x := range xs {
+		ys[i] = []byte{gfEval(coeffs, x)}
+	}
+	if got := gfInterpolateZero(xs, ys, 0); got != coeffs[0] {
+		t.Fatalf("gfInterpolateZero() = %#x, want %#x", got, coeffs[0])
+	}
+}
+
+func TestSplitCombineEverySubset(t *testing.T) {
+	shares, err := SplitPassword(testPassword, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("SplitPassword() made %d shares, want 5", len(shares))
+	}
+	for i := 0; i < len(shares); i++ {
+		for j := i + 1; j < len(shares); j++ {
+			for k := j + 1; k < len(shares); k++ {
+				// the order shares are given in shouldn't matter
+				subset := []string{shares[k], shares[i], shares[j]}
+				got, err := CombinePassword(subset)
+				if err != nil || got != testPassword {
+					t.Errorf("CombinePassword(%d, %d, %d) = %q, %v", i, j, k, got, err)
+				}
+			}
+		}
+	}
+	if got, err := CombinePassword(shares); err != nil || got != testPassword {
+		t.Errorf("CombinePassword(all) = %q, %v", got, err)
+	}
+}
+
+func TestCombineTooFewShares(t *testing.T) {
+	shares, err := SplitPassword(testPassword, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, given := range [][]string{nil, shares[:1], shares[3:]} {
+		if _, err := CombinePassword(given); !errors.Is(err, ErrInvalidShares) {
+			t.Errorf("CombinePassword(%d shares) = %v, want ErrInvalidShares", len(given), err)
+		}
+	}
+}
+
+func TestCombineMixedSplits(t *testing.T) {
+	a, err := SplitPassword(testPassword, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := SplitPassword(testPassword, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := SplitPassword(testPassword, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, mixed := range map[string][]string{
+		"same threshold":      {a[0], b[1]},
+		"different threshold": {a[0], a[1], c[2]},
+	} {
+		if _, err := CombinePassword(mixed); !errors.Is(err, ErrInvalidShares) {
+			t.Errorf("%s: CombinePassword() = %v, want ErrInvalidShares", name, err)
+		}
+	}
+}
+
+func TestCombineDuplicateShares(t *testing.T) {
+	shares, err := SplitPassword(testPassword, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dup := []string{shares[0], shares[0], shares[1]}
+	if _, err := CombinePassword(dup); !errors.Is(err, ErrInvalidShares) {
+		t.Errorf("CombinePassword(duplicate) = %v, want ErrInvalidShares", err)
+	}
+	dup = append(dup, shares[2])
+	if got, err := CombinePassword(dup); err != nil || got != testPassword {
+		t.Errorf("CombinePassword(duplicate plus enough) = %q, %v", got, err)
+	}
+}
+
+func TestCombineMalformedShares(t *testing.T) {
+	shares, err := SplitPassword(testPassword, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Split(shares[0], "-")
+	value := fields[2]
+	// flip the last hex digit so the share parses but is wrong
+	last := value[len(value)-1]
+	flipped := "0"
+	if last == '0' {
+		flipped = "1"
+	}
+	corrupt := strings.Join([]string{fields[0], fields[1], value[:len(value)-1] + flipped}, "-")
+	for name, share := range map[string]string{
+		"empty":            "",
+		"too few fields":   "2-1",
+		"bad threshold":    "x-1-" + value,
+		"threshold of one": "1-1-" + value,
+		"index zero":       "2-0-" + value,
+		"index too big":    "2-256-" + value,
+		"bad hex":          "2-1-zz" + value,
+		"too short":        "2-1-00112233",
+		"corrupted":        corrupt,
+	} {
+		if _, err := CombinePassword([]string{share, shares[1]}); !errors.Is(err, ErrInvalidShares) {
+			t.Errorf("%s: CombinePassword() = %v, want ErrInvalidShares", name, err)
+		}
+	}
+}
+
+func TestSplitPasswordBounds(t *testing.T) {
+	for _, c := range []struct{ parts, threshold int }{
+		{3, 1},
+		{3, 4},
+		{256, 2},
+	} {
+		if _, err := SplitPassword(testPassword, c.parts, c.threshold); !errors.Is(err, ErrInvalidShares) {
+			t.Errorf("SplitPassword(%d, %d) = %v, want ErrInvalidShares", c.parts, c.threshold, err)
+		}
+	}
+	if _, err := SplitPassword("", 3, 2); !errors.Is(err, ErrMissingPassword) {
+		t.Errorf("SplitPassword(\"\") = %v, want ErrMissingPassword", err)
+	}
+	for _, c := range []struct{ parts, threshold int }{
+		{2, 2},
+		{255, 2},
+		{255, 255},
+	} {
+		shares, err := SplitPassword(testPassword, c.parts, c.threshold)
+		if err != nil {
+			t.Fatalf("SplitPassword(%d, %d): %v", c.parts, c.threshold, err)
+		}
+		got, err := CombinePassword(shares[len(shares)-c.threshold:])
+		if err != nil || got != testPassword {
+			t.Errorf("CombinePassword() after SplitPassword(%d, %d) = %q, %v", c.parts, c.threshold, got, err)
+		}
+	}
+}
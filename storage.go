@@ -0,0 +1,191 @@
+package uggsec
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// The raw helpers below are the only place vault contents are
+// stored or fetched, which lets the same encryption code work on
+// top of a file, an in-memory buffer or a read-only fs.FS. They
+// deal in the encoded ciphertext exactly as it would appear on disk.
+
+// usesOSFiles reports whether the vault is stored in a file on the
+// OS file system, as opposed to in memory or in an fs.FS
+func (v *Vault) usesOSFiles() bool {
+	return !v.memory && v.fsys == nil
+}
+
+// writable returns ErrReadOnly for vaults that mustn't be modified,
+// those created with InitFS or with ReadOnly set
+func (v *Vault) writable() error {
+	if v.fsys != nil || v.readOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// readRaw returns the vault's stored ciphertext or ErrVaultNotFound
+// if nothing has been stored yet
+func (v *Vault) readRaw() ([]byte, error) {
+	if v.memory {
+		if v.memData == nil {
+			return nil, ErrVaultNotFound
+		}
+		return append([]byte(nil), v.memData...), nil
+	}
+	if v.fsys != nil {
+		data, err := fs.ReadFile(v.fsys, v.filename)
+		if err != nil {
+			return nil, notFoundError(err)
+		}
+		return data, nil
+	}
+	data, err := fileBackend.ReadFile(v.filename)
+	if err != nil {
+		return nil, notFoundError(err)
+	}
+	return data, nil
+}
+
+// writeRaw replaces the vault's stored ciphertext with data
+func (v *Vault) writeRaw(data []byte) error {
+	if err := v.writable(); err != nil {
+		return err
+	}
+	v.dropCache()
+	if v.memory {
+		v.memData = data
+		return nil
+	}
+	tmpName, err := writeTempFile(v.filename, data, v.fileMode)
+	if err != nil {
+		return err
+	}
+	err = v.replaceVaultFile(tmpName)
+	if err != nil {
+		return err
+	}
+	return v.updateChecksum()
+}
+
+// createRaw stores data as the vault's ciphertext only if nothing
+// is stored yet, reporting whether it did. On the OS file system the
+// data is written to a temp file that is then hard linked to the
+// vault's name. Linking fails if the name is taken, so of two racing
+// creators only one wins, and the vault file only ever appears with
+// all of its contents.
+func (v *Vault) createRaw(data []byte) (created bool, err error) {
+	if err := v.writable(); err != nil {
+		return false, err
+	}
+	if v.memory {
+		if v.memData != nil {
+			return false, nil
+		}
+		v.dropCache()
+		v.memData = data
+		return true, nil
+	}
+	tmpName, err := writeTempFile(v.filename, data, v.fileMode)
+	if err != nil {
+		return false, err
+	}
+	// the link, if made, keeps the contents, the temp name goes
+	// either way
+	defer fileBackend.Remove(tmpName)
+	if v.durable {
+		err = fileBackend.Sync(tmpName)
+		if err != nil {
+			return false, err
+		}
+	}
+	err = fileBackend.Link(tmpName, v.filename)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err == nil && v.durable {
+		err = syncDir(v.filename)
+	}
+	if err != nil {
+		return false, err
+	}
+	v.dropCache()
+	return true, v.updateChecksum()
+}
+
+// appendRaw adds data to the end of the vault's stored ciphertext,
+// creating it if nothing is stored yet
+func (v *Vault) appendRaw(data []byte) error {
+	if err := v.writable(); err != nil {
+		return err
+	}
+	v.dropCache()
+	if v.memory {
+		v.memData = append(v.memData, data...)
+		return nil
+	}
+	f, err := fileBackend.OpenFile(v.filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, v.fileMode)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil && v.durable {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && v.durable {
+		// the file may have just been created
+		err = syncDir(v.filename)
+	}
+	if err != nil {
+		return err
+	}
+	return v.updateChecksum()
+}
+
+// statRaw returns the size of the vault's stored ciphertext. When
+// nothing is stored the error satisfies errors.Is(err, os.ErrNotExist).
+func (v *Vault) statRaw() (size int64, err error) {
+	if v.memory {
+		if v.memData == nil {
+			return 0, os.ErrNotExist
+		}
+		return int64(len(v.memData)), nil
+	}
+	if v.fsys != nil {
+		info, err := fs.Stat(v.fsys, v.filename)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	info, err := fileBackend.Stat(v.filename)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// removeRaw discards the vault's stored ciphertext
+func (v *Vault) removeRaw() error {
+	if err := v.writable(); err != nil {
+		return err
+	}
+	v.dropCache()
+	if v.memory {
+		v.memData = nil
+		return nil
+	}
+	err := fileBackend.Remove(v.filename)
+	if err == nil && v.writeChecksum {
+		// a checksum for a file that's gone would only mislead
+		if rerr := fileBackend.Remove(v.filename + checksumSuffix); rerr != nil && !os.IsNotExist(rerr) {
+			return rerr
+		}
+	}
+	return err
+}
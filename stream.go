@@ -0,0 +1,470 @@
+package uggsec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// Stream files are stored as raw binary rather than base64 so they
+// can be written and read in a single pass. After the usual format
+// header the layout is salt, a random nonce prefix and then the
+// contents split into streamChunkSize pieces, each sealed with
+// AES-256-GCM under the header as associated data. A chunk's nonce
+// is the prefix, its big endian index and a byte that is 1 for the
+// final chunk and 0 otherwise, so chunks can't be reordered, and a
+// file cut short at a chunk boundary fails authentication instead
+// of reading back as shorter contents. There is always at least one
+// chunk, empty contents get a single empty final one.
+const (
+	streamChunkSize       = 64 << 10
+	streamNoncePrefixSize = 7
+)
+
+// Files written by earlier releases used AES-CTR instead: after the
+// header come the salt, IV, ciphertext and finally an HMAC-SHA256
+// over everything before it, header included.
+var (
+	streamIVSize  = aes.BlockSize
+	streamMACSize = sha256.Size
+)
+
+// WriteStream encrypts everything read from r into the vault's file
+// without holding the whole payload in memory. Only one 64 KiB
+// chunk is buffered regardless of how large r is, which makes this
+// suitable for backing up large secret blobs. Each chunk is sealed
+// with AES-256-GCM on its own, so integrity doesn't depend on a
+// trailer at the end of the file. As with Write the
+// output goes to a temp file that is renamed into place once the
+// stream has been fully consumed.
+//
+// Files written with WriteStream use a different layout than Write
+// and must be read back with ReadStream. In-memory vaults don't
+// support streaming and return ErrNotSupported, vaults created with
+// InitFS return ErrReadOnly.
+func (v *Vault) WriteStream(r io.Reader) (err error) {
+	defer wrapOp("write", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.observer != nil {
+		defer v.observeWrite(time.Now(), &err)
+	}
+	if err := v.writable(); err != nil {
+		return err
+	}
+	if v.memory {
+		return ErrNotSupported
+	}
+	v.log("Debug", "WriteStream(), getting password...")
+	password, err := v.getPassword()
+	if err != nil {
+		return err
+	}
+	defer wipe(password)
+	sec := make([]byte, saltSize+streamNoncePrefixSize)
+	_, err = io.ReadFull(v.randReader(), sec)
+	if err != nil {
+		return err
+	}
+	salt, prefix := sec[:saltSize], sec[saltSize:]
+	h, err := v.withKDF(newHeader(algStreamGCM))
+	if err != nil {
+		return err
+	}
+	gcm, err := newAESGCM(password, salt, keySize, h.kdf)
+	if err != nil {
+		return err
+	}
+	f, err := createTempFile(v.filename)
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	_, err = io.WriteString(f, h.String())
+	if err == nil {
+		_, err = f.Write(sec)
+	}
+	if err == nil {
+		v.log("Debug", "WriteStream(), encrypting stream...")
+		err = sealChunks(f, r, gcm, prefix, h.aad(nil))
+	}
+	if err == nil {
+		err = f.Chmod(v.fileMode)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fileBackend.Remove(tmpName)
+		return err
+	}
+	unlock, err := v.lockFile()
+	if err != nil {
+		fileBackend.Remove(tmpName)
+		return err
+	}
+	defer unlock()
+	v.log("Debug", "WriteStream(), replacing vault file...")
+	err = v.replaceVaultFile(tmpName)
+	if err != nil {
+		return err
+	}
+	return v.updateChecksum()
+}
+
+// ReadStream decrypts a file written by WriteStream into w. The
+// file is authenticated in a first pass before any plaintext is
+// written, so if it was modified, truncated or the wrong password
+// is used ErrTampered is returned and nothing reaches w. Like
+// WriteStream it only holds a chunk at a time in memory, at the
+// cost of reading the file from disk twice.
+func (v *Vault) ReadStream(w io.Writer) (err error) {
+	defer wrapOp("read", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.usesOSFiles() {
+		return ErrNotSupported
+	}
+	if v.observer != nil {
+		defer v.observeRead(time.Now(), &err)
+	}
+	rc, err := v.openStream()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	v.log("Debug", "ReadStream(), decrypting stream...")
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+// errNotStream is returned by openStream for files that hold
+// something other than a WriteStream ciphertext
+var errNotStream = fmt.Errorf("%w: vault was not written by WriteStream, read it with Read", ErrNotSupported)
+
+// openStream authenticates the stream file at the vault's filename
+// and returns a reader that decrypts it. The file stays open until
+// the reader is closed.
+func (v *Vault) openStream() (rc io.ReadCloser, err error) {
+	f, err := fileBackend.OpenFile(v.filename, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, notFoundError(err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+		}
+	}()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h := newHeader(algStreamGCM)
+	hdr := make([]byte, h.size())
+	_, err = io.ReadFull(f, hdr)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	if extra := headerExtra(string(hdr)); extra > 0 {
+		more := make([]byte, extra)
+		_, err = io.ReadFull(f, more)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, err
+		}
+		hdr = append(hdr, more...)
+	}
+	h, _, err = parseHeader(string(hdr))
+	if err != nil {
+		return nil, err
+	}
+	if h.algorithm != algStreamGCM && h.algorithm != algStreamCTRHMAC {
+		return nil, errNotStream
+	}
+	v.log("Debug", "openStream(), getting password...")
+	password, err := v.getPassword()
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(password)
+	var r io.Reader
+	if h.algorithm == algStreamCTRHMAC {
+		r, err = v.openCTRStream(f, h, info.Size(), password)
+	} else {
+		r, err = v.openGCMStream(f, h, info.Size(), password)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &streamReadCloser{Reader: r, f: f}, nil
+}
+
+// openGCMStream checks every chunk of the chunked GCM stream in f,
+// whose header h has already been read, then returns a reader that
+// decrypts the chunks again from the start. Each chunk is checked
+// again as it's read in case the file changed in between.
+func (v *Vault) openGCMStream(f file, h header, size int64, password []byte) (io.Reader, error) {
+	prefixSize := int64(h.size() + saltSize + streamNoncePrefixSize)
+	if size < prefixSize {
+		return nil, ErrTampered
+	}
+	sec := make([]byte, saltSize+streamNoncePrefixSize)
+	_, err := io.ReadFull(f, sec)
+	if err != nil {
+		return nil, err
+	}
+	salt, prefix := sec[:saltSize], sec[saltSize:]
+	gcm, err := newAESGCM(password, salt, keySize, h.kdf)
+	if err != nil {
+		return nil, err
+	}
+	chunks := func() *chunkReader {
+		return &chunkReader{
+			r:         io.NewSectionReader(f, prefixSize, size-prefixSize),
+			remaining: size - prefixSize,
+			gcm:       gcm,
+			prefix:    prefix,
+			ad:        h.aad(nil),
+		}
+	}
+	v.log("Debug", "openStream(), authenticating stream...")
+	_, err = io.Copy(io.Discard, chunks())
+	if err != nil {
+		return nil, err
+	}
+	return chunks(), nil
+}
+
+// openCTRStream authenticates the AES-CTR stream in f, whose header h
+// has already been read, and returns a reader that decrypts it
+func (v *Vault) openCTRStream(f file, h header, size int64, password []byte) (io.Reader, error) {
+	prefixSize := int64(h.size() + saltSize + streamIVSize)
+	bodyEnd := size - int64(streamMACSize)
+	if bodyEnd < prefixSize {
+		return nil, ErrTampered
+	}
+	sec := make([]byte, saltSize+streamIVSize)
+	_, err := io.ReadFull(f, sec)
+	if err != nil {
+		return nil, err
+	}
+	salt, iv := sec[:saltSize], sec[saltSize:]
+	block, macKey, err := newStreamCipher(password, salt, h.kdf)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(macKey)
+	v.log("Debug", "openStream(), authenticating stream...")
+	mac := hmac.New(sha256.New, macKey)
+	_, err = io.Copy(mac, io.NewSectionReader(f, 0, bodyEnd))
+	if err != nil {
+		return nil, err
+	}
+	sum := make([]byte, streamMACSize)
+	_, err = f.ReadAt(sum, bodyEnd)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return nil, ErrTampered
+	}
+	return &cipher.StreamReader{
+		S: cipher.NewCTR(block, iv),
+		R: io.NewSectionReader(f, prefixSize, bodyEnd-prefixSize),
+	}, nil
+}
+
+// sealChunks encrypts everything read from r into w as the chunks
+// of a GCM stream
+func sealChunks(w io.Writer, r io.Reader, gcm cipher.AEAD, prefix, ad []byte) error {
+	br := bufio.NewReader(r)
+	buf := make([]byte, streamChunkSize)
+	defer wipe(buf)
+	out := make([]byte, 0, streamChunkSize+gcm.Overhead())
+	nonce := make([]byte, gcm.NonceSize())
+	for index := uint64(0); ; index++ {
+		n, err := io.ReadFull(br, buf)
+		last := false
+		switch err {
+		case io.EOF, io.ErrUnexpectedEOF:
+			last = true
+		case nil:
+			// a full chunk is the last one if nothing follows it
+			_, err = br.Peek(1)
+			if err == io.EOF {
+				last = true
+			} else if err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+		if index > math.MaxUint32 {
+			return fmt.Errorf("stream is longer than %d chunks", uint64(math.MaxUint32)+1)
+		}
+		chunkNonce(nonce, prefix, uint32(index), last)
+		out = gcm.Seal(out[:0], nonce, buf[:n], ad)
+		_, err = w.Write(out)
+		if err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// chunkNonce fills nonce with the nonce for the chunk at index
+func chunkNonce(nonce, prefix []byte, index uint32, last bool) {
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[len(prefix):], index)
+	nonce[len(nonce)-1] = 0
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+}
+
+// chunkReader decrypts the chunks of a GCM stream one at a time.
+// Which chunk is the final one follows from where the stream ends,
+// remaining counts the ciphertext bytes not yet read. A chunk that
+// fails to open gives ErrTampered.
+type chunkReader struct {
+	r         io.Reader
+	remaining int64
+	gcm       cipher.AEAD
+	prefix    []byte
+	ad        []byte
+	index     uint32
+	done      bool
+	enc       []byte
+	buf       []byte
+	plain     []byte
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.plain) == 0 {
+		if c.done {
+			wipe(c.buf)
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			wipe(c.buf)
+			return 0, err
+		}
+	}
+	n := copy(p, c.plain)
+	c.plain = c.plain[n:]
+	return n, nil
+}
+
+// next reads and opens the following chunk
+func (c *chunkReader) next() error {
+	size := int64(streamChunkSize + c.gcm.Overhead())
+	last := c.remaining <= size
+	if last {
+		size = c.remaining
+	}
+	if size < int64(c.gcm.Overhead()) {
+		return ErrTampered
+	}
+	if c.enc == nil {
+		c.enc = make([]byte, streamChunkSize+c.gcm.Overhead())
+	}
+	enc := c.enc[:size]
+	_, err := io.ReadFull(c.r, enc)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, c.gcm.NonceSize())
+	chunkNonce(nonce, c.prefix, c.index, last)
+	c.buf, err = c.gcm.Open(c.buf[:0], nonce, enc, c.ad)
+	if err != nil {
+		return ErrTampered
+	}
+	c.plain = c.buf
+	c.remaining -= size
+	c.index++
+	c.done = last
+	return nil
+}
+
+// streamReadCloser decrypts a stream file and closes it when done
+type streamReadCloser struct {
+	io.Reader
+	f file
+}
+
+func (s *streamReadCloser) Close() error {
+	return s.f.Close()
+}
+
+// DecryptReader returns a reader over the vault's decrypted contents
+// for handing them to io.Copy, an HTTP response or another process
+// without converting them to a string first. Files written by
+// WriteStream are decrypted as they are read, in small chunks, while
+// other vaults are decrypted up front. Either way the contents are
+// authenticated before the reader is returned, so a modified file or
+// wrong password gives ErrTampered here rather than a reader that
+// stops short. Closing the reader closes the file or wipes the
+// decrypted buffer.
+func (v *Vault) DecryptReader() (rc io.ReadCloser, err error) {
+	defer wrapOp("read", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.usesOSFiles() {
+		rc, err := v.openStream()
+		if err == nil {
+			return rc, nil
+		}
+		// headerless legacy files are left to loadFromDisk, which
+		// decides whether they may be read
+		if err != errNotStream && !errors.Is(err, ErrLegacyFormat) {
+			return nil, err
+		}
+	}
+	b, err := v.loadFromDisk(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &bufferReadCloser{Reader: bytes.NewReader(b), b: b}, nil
+}
+
+// bufferReadCloser reads decrypted contents held in memory and
+// wipes them on Close
+type bufferReadCloser struct {
+	*bytes.Reader
+	b []byte
+}
+
+func (r *bufferReadCloser) Close() error {
+	wipe(r.b)
+	r.Reader.Reset(nil)
+	return nil
+}
+
+// newStreamCipher derives separate encryption and MAC keys from
+// password so the same key material is never used for both.
+func newStreamCipher(password, salt []byte, kdf KDFParams) (block cipher.Block, macKey []byte, err error) {
+	key, err := deriveKey(password, salt, 2*keySize, kdf)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the cipher keeps its own expanded copy of the key
+	block, err = aes.NewCipher(key[:keySize])
+	wipe(key[:keySize])
+	if err != nil {
+		wipe(key)
+		return nil, nil, err
+	}
+	return block, key[keySize:], nil
+}
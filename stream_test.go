@@ -0,0 +1,202 @@
+package uggsec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// streamPayload returns n bytes that don't repeat in chunk sized
+// blocks, so mixed up chunks can't decrypt to the right thing
+func streamPayload(n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(b)
+	return b
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	v := newTestVault(t, nil)
+	for _, n := range []int{0, 1, streamChunkSize, 3*streamChunkSize + 7} {
+		payload := streamPayload(n)
+		if err := v.WriteStream(bytes.NewReader(payload)); err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if err := v.ReadStream(&out); err != nil {
+			t.Fatalf("%d bytes: ReadStream() = %v", n, err)
+		}
+		if !bytes.Equal(out.Bytes(), payload) {
+			t.Fatalf("%d bytes: ReadStream() returned %d different bytes", n, out.Len())
+		}
+	}
+}
+
+func TestStreamTampered(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.WriteStream(bytes.NewReader(streamPayload(2*streamChunkSize + 100))); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 1
+	if err := os.WriteFile(v.filename, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := v.ReadStream(&out); !errors.Is(err, ErrTampered) {
+		t.Fatalf("ReadStream() = %v, want ErrTampered", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("ReadStream() wrote %d bytes of a tampered stream", out.Len())
+	}
+}
+
+func TestReadStreamRejectsOtherVaults(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("not a stream"); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := v.ReadStream(&out); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("ReadStream() = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestStreamNotSupportedInMemory(t *testing.T) {
+	v, err := InitMemory(&VaultInput{KDFParams: KDFParams{N: minKDFN, R: 1, P: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.WriteStream(bytes.NewReader(nil)); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("WriteStream() = %v, want ErrNotSupported", err)
+	}
+}
+
+// rewriteStream writes a stream of three chunks, the last one short,
+// lets edit change the sealed chunks and returns the vault
+func rewriteStream(t *testing.T, edit func(prefix []byte, chunks [][]byte) []byte) *Vault {
+	t.Helper()
+	v := newTestVault(t, nil)
+	if err := v.WriteStream(bytes.NewReader(streamPayload(2*streamChunkSize + 100))); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _, err := parseHeader(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefixSize := h.size() + saltSize + streamNoncePrefixSize
+	sealed := streamChunkSize + 16
+	body := data[prefixSize:]
+	chunks := [][]byte{body[:sealed], body[sealed : 2*sealed], body[2*sealed:]}
+	if err := os.WriteFile(v.filename, edit(data[:prefixSize], chunks), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestStreamTruncated(t *testing.T) {
+	// putting the chunks back as they were has to still read, or the
+	// cases below would fail for the wrong reason
+	v := rewriteStream(t, func(p []byte, c [][]byte) []byte { return join(p, c[0], c[1], c[2]) })
+	if err := v.ReadStream(io.Discard); err != nil {
+		t.Fatalf("ReadStream() of the reassembled stream = %v", err)
+	}
+	for name, edit := range map[string]func(prefix []byte, chunks [][]byte) []byte{
+		"final chunk dropped": func(p []byte, c [][]byte) []byte { return join(p, c[0], c[1]) },
+		"cut mid chunk":       func(p []byte, c [][]byte) []byte { return join(p, c[0], c[1][:1000]) },
+		"chunks reordered":    func(p []byte, c [][]byte) []byte { return join(p, c[1], c[0], c[2]) },
+		"chunk repeated":      func(p []byte, c [][]byte) []byte { return join(p, c[0], c[0], c[1], c[2]) },
+		"only the header":     func(p []byte, c [][]byte) []byte { return p },
+	} {
+		t.Run(name, func(t *testing.T) {
+			v := rewriteStream(t, edit)
+			var out bytes.Buffer
+			if err := v.ReadStream(&out); !errors.Is(err, ErrTampered) {
+				t.Fatalf("ReadStream() = %v, want ErrTampered", err)
+			}
+			if out.Len() != 0 {
+				t.Fatalf("ReadStream() wrote %d bytes", out.Len())
+			}
+		})
+	}
+}
+
+// readAllClose reads rc to the end and closes it
+func readAllClose(rc io.ReadCloser) ([]byte, error) {
+	b, err := io.ReadAll(rc)
+	if cerr := rc.Close(); err == nil {
+		err = cerr
+	}
+	return b, err
+}
+
+func TestDecryptReaderRoundTrip(t *testing.T) {
+	v := newTestVault(t, nil)
+	payload := streamPayload(2*streamChunkSize + 9)
+	if err := v.WriteStream(bytes.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := v.DecryptReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readAllClose(rc); err != nil || !bytes.Equal(got, payload) {
+		t.Fatalf("DecryptReader() of a stream read %d bytes, %v", len(got), err)
+	}
+	if err := v.Write("not a stream"); err != nil {
+		t.Fatal(err)
+	}
+	rc, err = v.DecryptReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readAllClose(rc); err != nil || string(got) != "not a stream" {
+		t.Fatalf("DecryptReader() of a Write = %q, %v", got, err)
+	}
+}
+
+func TestDecryptReaderTampered(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.WriteStream(bytes.NewReader(streamPayload(streamChunkSize + 1))); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 1
+	if err := os.WriteFile(v.filename, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.DecryptReader(); !errors.Is(err, ErrTampered) {
+		t.Fatalf("DecryptReader() = %v, want ErrTampered", err)
+	}
+}
+
+func TestDecryptReaderLegacy(t *testing.T) {
+	name := copyLegacyFixture(t)
+	v, err := InitPassword(&VaultInput{Filename: name, Password: legacyPassword, AllowLegacy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := v.DecryptReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readAllClose(rc); err != nil || string(got) != "hello from an old release" {
+		t.Fatalf("DecryptReader() = %q, %v", got, err)
+	}
+}
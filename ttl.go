@@ -0,0 +1,49 @@
+package uggsec
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// expirySize is the length of the expiry prefix flagExpiry adds to
+// the plaintext
+const expirySize = 8
+
+// WriteWithTTL behaves like Write but the contents expire once ttl
+// has passed. The expiry time is stored inside the ciphertext so it
+// is covered by authentication and can't be pushed back by editing
+// the file. Reads after that time return ErrExpired, and if the
+// vault was created with DeleteExpired the file is removed as well.
+func (v *Vault) WriteWithTTL(contents string, ttl time.Duration) (err error) {
+	defer wrapOp("write", v.filename, &err)
+	if ttl <= 0 {
+		return fmt.Errorf("ttl must be greater than zero, got %v", ttl)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	expiry := v.nowFunc().Add(ttl)
+	v.log("Debug", "WriteWithTTL(), writing contents with expiry...", "expiry", expiry.Format(time.RFC3339))
+	h, body, err := v.wrapPlainText(newHeader(algAES256GCM), []byte(contents))
+	if err != nil {
+		return err
+	}
+	b := make([]byte, expirySize, expirySize+len(body))
+	binary.BigEndian.PutUint64(b, uint64(expiry.UnixNano()))
+	h.flags |= flagExpiry
+	return v.writeBytesHeader(context.Background(), h, append(b, body...), v.associatedData)
+}
+
+// checkExpiry enforces the expiry at the start of plainText, as of
+// now, and returns what follows it
+func checkExpiry(plainText []byte, now time.Time) ([]byte, error) {
+	if len(plainText) < expirySize {
+		return nil, ErrTampered
+	}
+	expiry := time.Unix(0, int64(binary.BigEndian.Uint64(plainText)))
+	if now.After(expiry) {
+		return nil, fmt.Errorf("%w: at %s", ErrExpired, expiry.Format(time.RFC3339))
+	}
+	return plainText[expirySize:], nil
+}
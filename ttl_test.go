@@ -0,0 +1,90 @@
+package uggsec
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWriteWithTTLExpires(t *testing.T) {
+	clock := newFakeClock()
+	v := newTestVault(t, &VaultInput{Clock: clock.Now})
+	if err := v.WriteWithTTL("short lived", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(59 * time.Minute)
+	if got, err := v.Read(); err != nil || got != "short lived" {
+		t.Fatalf("Read() before expiry = %q, %v", got, err)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := v.Read(); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Read() after expiry = %v, want ErrExpired", err)
+	}
+	if _, err := os.Stat(v.filename); err != nil {
+		t.Fatalf("expired file was removed without DeleteExpired: %v", err)
+	}
+}
+
+func TestWriteWithTTLDeleteExpired(t *testing.T) {
+	clock := newFakeClock()
+	v := newTestVault(t, &VaultInput{Clock: clock.Now, DeleteExpired: true})
+	if err := v.WriteWithTTL("short lived", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if _, err := v.Read(); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Read() after expiry = %v, want ErrExpired", err)
+	}
+	if _, err := os.Stat(v.filename); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expired file still there: %v", err)
+	}
+}
+
+func TestWriteWithTTLRejectsNonPositive(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.WriteWithTTL("x", 0); err == nil {
+		t.Fatal("WriteWithTTL() accepted a zero ttl")
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.WriteWithTTL("x", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	v.withClock(func() time.Time { return time.Now().Add(2 * time.Hour) })
+	if _, err := v.Read(); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Read() = %v, want ErrExpired", err)
+	}
+}
+
+func TestWriteWithTTLOneMillisecond(t *testing.T) {
+	clock := newFakeClock()
+	v := newTestVault(t, &VaultInput{Clock: clock.Now})
+	if err := v.WriteWithTTL("blink", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.Read(); err != nil || got != "blink" {
+		t.Fatalf("Read() within the ttl = %q, %v", got, err)
+	}
+	clock.Advance(time.Millisecond + time.Nanosecond)
+	if _, err := v.Read(); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Read() = %v, want ErrExpired", err)
+	}
+}
+
+func TestRotateKeepsExpiry(t *testing.T) {
+	clock := newFakeClock()
+	v := newTestVault(t, &VaultInput{Clock: clock.Now})
+	if err := v.WriteWithTTL("x", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Rotate(NewVaultPassword()); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Hour)
+	if _, err := v.Read(); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Read() after Rotate = %v, want the expiry kept", err)
+	}
+}
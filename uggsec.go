@@ -1,119 +1,505 @@
 // Package uggsec provides objects and methods for securely storing contents
 // to files using encryption. The decryption password is either stored in the
-// OS keyring or in an ENV variable that the user specifies. 
+// OS keyring or in an ENV variable that the user specifies.
 package uggsec
 
 import (
-	"crypto/aes"
+	"context"
 	"crypto/cipher"
+	crand "crypto/rand"
 	"encoding/base64"
-	"github.com/zalando/go-keyring"
-	"github.com/inconshreveable/log15"
-	"fmt"
-	"io/ioutil"
+	"encoding/hex"
 	"errors"
-	"math/rand"
+	"fmt"
+	"github.com/inconshreveable/log15"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
-	"os"
+	"unicode/utf8"
 )
 
 var (
-	bytes = []byte{35, 46, 57, 24, 85, 35, 24, 74, 87, 35, 88, 98, 66, 32, 14, 05}
-	keySize = 32
-	letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ123456789")
+	keySize         = 32
+	saltSize        = 16
+	defaultFileMode = os.FileMode(0600)
+
+	// defaultPasswordEnvVar is the ENV var InitSmart falls back to
+	// when the keyring is unavailable and PasswordEnvVar is empty
+	defaultPasswordEnvVar = "UGGSECP"
+
+	// minPasswordLength is the shortest password accepted from an
+	// ENV var. Keys are derived with scrypt so any length works
+	// technically, this just rules out trivially guessable values.
+	minPasswordLength = 16
 )
 
 type VaultInput struct {
 	// For systems that support KeyRings this is the label
-	// that the password will be stored under in the keyring
+	// that the password will be stored under in the keyring.
+	// Both are required for keyring vaults.
 	Service, User string
 
+	// DisableKeyring stops this package from touching the OS
+	// keyring at all. go-keyring picks its backend itself and on
+	// headless Linux boxes probing secret-service over DBus can
+	// take a long time to fail, so containers and CI usually want
+	// this set along with PasswordEnvVar. InitSmart then never
+	// falls back to the keyring and InitKeyring refuses to run.
+	DisableKeyring bool
+
 	// On systems where no keyring is available this package
 	// use of a password stored in this environment
-	// variable. Must contain predetermined length byte string 
-	// as defined by keySize in this package. This
-	// package has a helper function NewPassword which honors 
-	// keySize and can be used to set your ENV var's contents.
+	// variable. The password can be any length of at least
+	// 16 characters since the encryption key is derived from
+	// it with scrypt. This package has a helper function
+	// NewVaultPassword which can be used to set your ENV var's
+	// contents.
 	PasswordEnvVar string
 
-	// Filename of the encrypted file that should be used for 
+	// PasswordEnvVarEncoding is how the password is written in
+	// PasswordEnvVar: PasswordEncodingRaw, the default, or
+	// PasswordEncodingBase64 for passwords whose characters don't
+	// survive shell quoting. The minimum length applies to the
+	// decoded password.
+	PasswordEnvVarEncoding PasswordEncoding
+
+	// Password can be set by callers that already have the
+	// password in hand, for example after prompting for it
+	// interactively, so it doesn't have to go through the
+	// keyring or an ENV var. Its type keeps it from showing
+	// up when a VaultInput is printed or logged.
+	Password Secret
+
+	// Filename of the encrypted file that should be used for
 	// storing this vault's contents
 	Filename string
+
+	// UseFileLock makes writes take an advisory lock on a
+	// Filename+".lock" sibling file so that several processes
+	// sharing a vault don't corrupt it by writing at once.
+	UseFileLock bool
+
+	// FileLockTimeout is how long a write waits for another
+	// process to release the lock before giving up with
+	// ErrLockTimeout. Defaults to 10 seconds when zero.
+	FileLockTimeout time.Duration
+
+	// FileMode is the permission the vault file is written
+	// with. Defaults to 0600 when zero. Modes that let other
+	// users write the file are allowed but logged as a warning.
+	FileMode os.FileMode
+
+	// Logger optionally receives this vault's debug messages in
+	// addition to the package level Loggo, such as which password
+	// mechanism was picked, whether a new keyring secret or vault
+	// file had to be created, and why the keyring couldn't be
+	// used. When nil nothing extra is logged.
+	Logger Logger
+
+	// DeleteExpired makes reads of a vault written with
+	// WriteWithTTL remove the file once it has expired, in
+	// addition to returning ErrExpired.
+	DeleteExpired bool
+
+	// Deterministic makes writing the same contents with the same
+	// password produce byte for byte the same file, for callers
+	// that need to deduplicate or make idempotent writes. This
+	// reveals when two vaults, or two versions of one vault, hold
+	// the same contents so leave it off unless that is needed.
+	// Vaults written either way can be read either way.
+	Deterministic bool
+
+	// Compress deflates contents before encrypting them, which
+	// saves space for large structured secrets such as JSON
+	// configs. Files record whether they were compressed so
+	// vaults read correctly whatever this is set to. Leave it off
+	// when contents mix secrets with data an attacker can
+	// influence: the compressed length then leaks how much of the
+	// attacker's data matched the secret, as in the CRIME attack.
+	Compress bool
+
+	// KeepBackup makes every write first preserve the current
+	// vault file as Filename+".bak" so the previous version can be
+	// recovered with ReadBackup if the new one turns out to be
+	// bad. Only one backup is kept. After Rotate the backup is
+	// still encrypted with the old password.
+	KeepBackup bool
+
+	// AssociatedData binds the vault's ciphertext to some context,
+	// such as the filename or a tenant ID, without storing it in
+	// the file. Reading with different associated data than the
+	// contents were written with fails with ErrTampered, so a vault
+	// file copied into another tenant's place won't decrypt there.
+	// Files written by WriteStream aren't covered.
+	AssociatedData []byte
+
+	// Observer optionally receives the duration and outcome of
+	// every read, write and keyring call the vault makes. When
+	// nil nothing is timed.
+	Observer Observer
+
+	// SecondaryPasswordEnvVar turns on dual control: the
+	// encryption key is derived from the vault's usual password
+	// together with the password in this ENV var, so neither
+	// alone can decrypt the vault and reads fail with
+	// ErrMissingPassword when either is missing. Each can be held
+	// by a different person or system for separation of duties.
+	// Rotate only replaces the primary password.
+	SecondaryPasswordEnvVar string
+
+	// CreateIfMissing makes the Init methods create an empty
+	// vault, as Vault.Init does, when Filename doesn't exist yet.
+	// Without it a missing file is left alone and reads return
+	// ErrVaultNotFound until something is written.
+	CreateIfMissing bool
+
+	// KDFParams sets the cost of deriving the encryption key from
+	// the password. The zero value uses the defaults described on
+	// KDFParams.
+	KDFParams KDFParams
+
+	// CacheReads keeps the decrypted contents in memory after a
+	// read and returns them from there as long as the vault's file
+	// keeps the same modification time and size, skipping the file
+	// read and key derivation. Anything written through the vault
+	// drops the cache. A change made by another process within the
+	// file system's timestamp resolution that leaves the size alone
+	// can go unnoticed, as can a change to the password that isn't
+	// made through Rotate. Contents with an expiry aren't cached.
+	CacheReads bool
+
+	// KeySize is the AES key size in bytes used for the vault's
+	// contents: 16, 24 or 32 for AES-128, AES-192 or AES-256. Zero
+	// means 32. The choice is recorded in the header so readers
+	// don't need to know it. WriteStream always uses AES-256 and
+	// ChaCha20-Poly1305 only takes 32.
+	KeySize int
+
+	// Algorithm selects the AEAD the vault's contents are sealed
+	// with: AlgorithmAESGCM, the default, or
+	// AlgorithmChaCha20Poly1305 for CPUs without AES instructions.
+	// The choice is recorded in the header so readers don't need
+	// to know it. Streams and WriteToRecipient are unaffected.
+	Algorithm Algorithm
+
+	// RawBinary stores the ciphertext as raw bytes after the
+	// header instead of base64 encoding it, saving about a quarter
+	// of the file size. Encrypt returns the same binary form. Leave
+	// it off if vault contents get copied through text channels.
+	// Readers detect the encoding from the header either way.
+	RawBinary bool
+
+	// Encoding is how the ciphertext after the header is written
+	// out when RawBinary is off: EncodingBase64, the default, or
+	// EncodingHex for pipelines that only pass hex through. Hex
+	// files are about half again as large as base64 ones. The
+	// choice is recorded in the header, readers detect it.
+	Encoding Encoding
+
+	// ValidateUTF8 makes Read and ReadContext return ErrInvalidUTF8
+	// when the decrypted contents aren't valid UTF-8, as happens
+	// when binary data stored with WriteBytes is read back through
+	// the string API. A wrong password is already reported as
+	// ErrTampered, this is only a heuristic for contents that
+	// decrypted fine but aren't text. ReadBytes skips the check.
+	ValidateUTF8 bool
+
+	// PasswordFile names a file holding the password, such as a
+	// Docker or Kubernetes secret mounted at /run/secrets. See
+	// InitPasswordFile. InitSmart prefers it over the keyring and
+	// PasswordEnvVar but not over Password.
+	PasswordFile string
+
+	// WriteChecksum stores the SHA-256 of the vault's file in a
+	// sidecar file with a .sha256 suffix every time the vault is
+	// written, for VerifyIntegrity or sha256sum to check later.
+	// It has no effect on in-memory and fs.FS vaults.
+	WriteChecksum bool
+
+	// ReadOnly makes every method that would modify the vault, its
+	// file or its password, such as Write, Append, Delete and
+	// Rotate, return ErrReadOnly before doing anything, for
+	// services that should only ever read their secrets. Reading
+	// works as usual. A keyring vault with no secret stored yet
+	// gets ErrNoKeyringSecret from InitKeyring rather than a new
+	// password being stored, and expired contents are left in
+	// place even with DeleteExpired.
+	ReadOnly bool
+
+	// PasswordCacheTTL keeps the password in memory for this long
+	// after it's fetched from the keyring, PasswordFile or ENV var,
+	// so reads and writes in the meantime skip the lookup. That
+	// helps where every keyring access prompts the user or costs a
+	// DBus round trip. The tradeoff is exposure: the password sits
+	// in the process's memory for the whole TTL instead of only
+	// for the duration of each operation, and a password changed
+	// outside the vault isn't noticed until the TTL is up. The
+	// cached copy is wiped when it expires and whenever the
+	// password is changed through the vault, as Rotate does. Zero
+	// turns the cache off.
+	PasswordCacheTTL time.Duration
+
+	// InsecureRandSource replaces crypto/rand as where the vault
+	// gets its salts, nonces and generated passwords, so tests can
+	// pass a seeded reader and get the same ciphertext on every run
+	// for golden files. Never set it outside tests: anyone who can
+	// guess the reader's output can recover generated passwords,
+	// and a reader that repeats itself reuses nonces, which breaks
+	// AES-GCM outright. Every vault created with it logs a warning.
+	// File names for temp files still come from crypto/rand.
+	InsecureRandSource io.Reader
+
+	// Durable flushes every write to stable storage before
+	// returning: the new file is synced before it's renamed over
+	// the vault file and the directory is synced after, so the
+	// write survives a power loss rather than only being atomic.
+	// Each fsync can take milliseconds or far longer on busy or
+	// network disks, often more than the write itself, so only
+	// turn it on for vaults whose loss would be catastrophic. It
+	// has no effect on in-memory and fs.FS vaults.
+	Durable bool
+
+	// AllowLegacy lets Read and the methods built on it decrypt
+	// files written by releases that predate the versioned header,
+	// which used unauthenticated AES-CFB, instead of returning
+	// ErrLegacyFormat. Such files can't be checked for tampering,
+	// a modified one decrypts to whatever the modification makes
+	// of it, so only set it while moving existing vaults over and
+	// prefer running Migrate. It's ignored when AssociatedData is
+	// set since the legacy format can't be bound to it.
+	AllowLegacy bool
+
+	// Clock replaces time.Now as where the vault gets the time for
+	// TTL expiry, the password cache and password age, so tests can
+	// move time forward instead of sleeping. Nil uses time.Now.
+	Clock func() time.Time
 }
 
 // Vault provides methods for reading and writing
 // encrypted contents to files. Use the Init methods provided
 // by this package to obtain a Vault object.
+//
+// A Vault is safe for concurrent use by multiple goroutines, its
+// file operations are serialized by an internal lock. The lock
+// only protects access from within the current process, it does
+// nothing to stop another process from writing the same file.
 type Vault struct {
-	service, user string
-	filename string
+	mu             sync.Mutex
+	service, user  string
+	filename       string
 	passwordEnvVar string
-	keyring bool
+	envEncoding    PasswordEncoding
+	keyring        bool
+	ring           keyringProvider
+	fileLock       bool
+	lockTimeout    time.Duration
+	fileMode       os.FileMode
+	password       []byte
+	memory         bool
+	memData        []byte
+	fsys           fs.FS
+	logger         Logger
+	deleteExpired  bool
+	deterministic  bool
+	compress       bool
+	keepBackup     bool
+	associatedData []byte
+	observer       Observer
+	secondaryVar   string
+	kdf            KDFParams
+	cacheReads     bool
+	cache          *readCache
+	keyLen         int
+	algorithm      Algorithm
+	rawBinary      bool
+	encoding       Encoding
+	obfuscate      bool
+	validateUTF8   bool
+	passwordFile   string
+	writeChecksum  bool
+	readOnly       bool
+	passwordTTL    time.Duration
+	pwCache        passwordCache
+	randSource     io.Reader
+	durable        bool
+	allowLegacy    bool
+	legacyNotice   sync.Once
+
+	// nowFunc is where the vault gets the time for expiry checks,
+	// see VaultInput.Clock
+	nowFunc func() time.Time
+}
+
+// newVault returns a vault with the settings from i that are
+// shared by every password mechanism filled in
+func newVault(i *VaultInput) *Vault {
+	v := &Vault{
+		filename:    i.Filename,
+		fileLock:    i.UseFileLock,
+		lockTimeout: i.FileLockTimeout,
+		fileMode:    i.FileMode,
+		logger:      i.Logger,
+		observer:    i.Observer,
+
+		deleteExpired: i.DeleteExpired,
+		deterministic: i.Deterministic,
+		compress:      i.Compress,
+		keepBackup:    i.KeepBackup,
+		secondaryVar:  i.SecondaryPasswordEnvVar,
+		envEncoding:   i.PasswordEnvVarEncoding,
+		kdf:           i.KDFParams,
+		cacheReads:    i.CacheReads,
+		keyLen:        i.KeySize,
+		algorithm:     i.Algorithm,
+		rawBinary:     i.RawBinary,
+		encoding:      i.Encoding,
+		validateUTF8:  i.ValidateUTF8,
+		writeChecksum: i.WriteChecksum,
+		readOnly:      i.ReadOnly,
+		passwordTTL:   i.PasswordCacheTTL,
+		randSource:    i.InsecureRandSource,
+		durable:       i.Durable,
+		allowLegacy:   i.AllowLegacy,
+		nowFunc:       time.Now,
+
+		associatedData: append([]byte(nil), i.AssociatedData...),
+	}
+	if i.Clock != nil {
+		v.nowFunc = i.Clock
+	}
+	if v.lockTimeout == 0 {
+		v.lockTimeout = defaultLockTimeout
+	}
+	if v.fileMode == 0 {
+		v.fileMode = defaultFileMode
+	}
+	if v.fileMode.Perm()&0002 != 0 {
+		v.log("Warn", "newVault(), vault file mode is world-writable", "file", v.filename, "mode", v.fileMode.String())
+	}
+	if v.randSource != nil {
+		v.log("Warn", "newVault(), vault uses InsecureRandSource instead of crypto/rand, only do this in tests",
+			"file", v.filename)
+	}
+	return v
 }
 
 // InitSmart tries to determine the best method of Vault instantiation
-// based on the provided input param struct.
+// based on the provided input param struct. An explicit Password wins
+// over everything else, followed by PasswordFile. Otherwise, when the
+// Service and User keyring labels are set the keyring is preferred,
+// and if it turns out to be unavailable (ErrKeyringUnavailable) the
+// vault falls back to the PasswordEnvVar ENV var, or UGGSECP when
+// that is empty. Any other keyring failure, such as a locked
+// keyring, is returned rather than silently switching mechanisms.
+// Without keyring labels, or with DisableKeyring set, PasswordEnvVar
+// is used directly. Use Mechanism on the returned vault to find out
+// which one was settled on.
 func InitSmart(i *VaultInput) (*Vault, error) {
+	if i.Password != "" {
+		logTo(i.Logger, "Debug", "InitSmart(), Password set, using it directly")
+		return InitPassword(i)
+	}
+	if i.PasswordFile != "" {
+		logTo(i.Logger, "Debug", "InitSmart(), PasswordFile set, using it", "file", i.PasswordFile)
+		return InitPasswordFile(i)
+	}
+	if !i.DisableKeyring && i.Service != "" && i.User != "" {
+		logTo(i.Logger, "Debug", "InitSmart(), keyring labels set, trying keyring")
+		v, err := InitKeyring(i)
+		if !errors.Is(err, ErrKeyringUnavailable) {
+			return v, err
+		}
+		fallback := *i
+		if fallback.PasswordEnvVar == "" {
+			fallback.PasswordEnvVar = defaultPasswordEnvVar
+		}
+		logTo(i.Logger, "Info", "InitSmart(), keyring unavailable, falling back to env var",
+			"var", fallback.PasswordEnvVar, "error", err.Error())
+		return InitEnvVar(&fallback)
+	}
 	if i.PasswordEnvVar != "" {
-		return(InitEnvVar(i))
+		logTo(i.Logger, "Debug", "InitSmart(), PasswordEnvVar set, using env var", "var", i.PasswordEnvVar)
+		return (InitEnvVar(i))
+	}
+	if i.DisableKeyring {
+		logTo(i.Logger, "Debug", "InitSmart(), no PasswordEnvVar set and keyring disabled")
+		return nil, fmt.Errorf("%w: PasswordEnvVar, PasswordFile or Password must be set when DisableKeyring is", ErrMissingPassword)
 	}
+	logTo(i.Logger, "Debug", "InitSmart(), no PasswordEnvVar set, using keyring")
 	return InitKeyring(i)
 }
 
-// InitKeyring initializes a new or existing vault so that the 
+// InitKeyring initializes a new or existing vault so that the
 // Read and Write methods can be called on the returned vault. It
 // attempts to retrieve a password from the OS keyring stored under
-// the provided Service and User label. If no password can be retrieved
-// then one is created. If no existing vault file can be found then one
-// is created. If it fails to load the OS keyring then an error is returned
-// so the user could instead call the NewPassword and InitEnvVar methods as
-// an alternative.  
+// the provided Service and User label. If no password can be
+// retrieved then one is created. If no existing vault file can be
+// found then one is only created when CreateIfMissing is set. If it
+// fails to load the OS keyring then an error is returned so the user
+// could instead call the NewPassword and InitEnvVar methods as an
+// alternative. Service and User must both be set, otherwise
+// ErrMissingKeyringLabel is returned before the keyring is touched.
 func InitKeyring(i *VaultInput) (*Vault, error) {
 	var err error
-	v := Vault{
-		service: i.Service,
-		user: i.User,
-		filename: i.Filename,
+	v := newVault(i)
+	if i.DisableKeyring {
+		return v, fmt.Errorf("%w: keyring is disabled", ErrNotSupported)
 	}
+	if i.Service == "" || i.User == "" {
+		return v, fmt.Errorf("%w: got service %q user %q", ErrMissingKeyringLabel, i.Service, i.User)
+	}
+	v.service = i.Service
+	v.user = i.User
+	v.ring = newChunkedKeyring(v.observeKeyring(keyringBackend))
 	// see if existing keyring password exists
-	_, err = keyring.Get(v.service, v.user)
+	v.log("Debug", "InitKeyring(), looking up keyring secret", "service", v.service, "user", v.user)
+	_, err = v.getPasswordKeyring()
 	if err != nil {
-		if strings.Contains(err.Error(), "secret not found in keyring") {
+		if errors.Is(err, ErrNoKeyringSecret) {
 			// means keyring works but no password for this service/user yet
-			err = initKeyring(v.service, v.user)
+			if v.readOnly {
+				return v, err
+			}
+			v.log("Debug", "InitKeyring(), no keyring secret yet, creating new password")
+			err = v.initKeyring()
 			if err != nil {
-				return &v, err
+				v.log("Debug", "InitKeyring(), unable to store new password in keyring", "error", err.Error())
+				return v, err
 			}
 			v.keyring = true
+			v.recordRotation()
 		} else {
-			return &v, err
+			v.log("Debug", "InitKeyring(), keyring unavailable", "error", err.Error())
+			return v, err
 		}
+	} else {
+		v.log("Debug", "InitKeyring(), found existing keyring secret")
 	}
 	v.keyring = true
 	// now try to load file
-	_, err = v.loadFromDisk()
+	_, err = v.loadFromDisk(context.Background())
 	if err != nil {
-		log("Debug", "InitKeyring(), error loading file from disk", "error", err.Error())
-		if detectFileNotFoundError(err) {
-			// create new file by writing nothing to it
-			log("Debug", "InitKeyring(), attempting to create blank file")
-			err = v.Write("")
+		v.log("Debug", "InitKeyring(), error loading file from disk", "error", err.Error())
+		if errors.Is(err, ErrVaultNotFound) {
+			err = nil
+			if i.CreateIfMissing {
+				v.log("Debug", "InitKeyring(), attempting to create blank file")
+				err = v.Init()
+				if err == nil {
+					v.log("Debug", "InitKeyring(), created new vault file", "file", v.filename)
+				}
+			}
 		}
 	}
-	return &v, err
-}
-
-// looks for common "file not found" type error messages across
-// different systems
-func detectFileNotFoundError(err error) (bool) {
-	if strings.Contains(err.Error(), "system cannot find the file specified") {
-		return true
-	}
-	if strings.Contains(err.Error(), "no such file or directory") {
-		return true
-	}
-	return false
+	return v, err
 }
 
 // InitEnvVar initializes a new or existing vault using the password stored
@@ -121,150 +507,1313 @@ func detectFileNotFoundError(err error) (bool) {
 // then be written and read using the Write and Read methods.
 func InitEnvVar(i *VaultInput) (*Vault, error) {
 	var err error
-	v := Vault{
-		filename: i.Filename,
-		passwordEnvVar: i.PasswordEnvVar,
-	}
+	v := newVault(i)
+	v.passwordEnvVar = i.PasswordEnvVar
 	_, err = v.getPassword()
 	if err != nil {
-		return &v, err
+		return v, err
+	}
+	_, err = v.loadFromDisk(context.Background())
+	if err != nil {
+		v.log("Debug", "InitEnvVar(), error loading file from disk", "error", err.Error())
+		if errors.Is(err, ErrVaultNotFound) {
+			err = nil
+			if i.CreateIfMissing {
+				v.log("Debug", "InitEnvVar(), attempting to create blank file")
+				err = v.Init()
+			}
+		}
+	}
+	return v, err
+}
+
+// InitPassword initializes a new or existing vault that encrypts
+// and decrypts with the Password field of i rather than looking one
+// up in the keyring or an ENV var. The password is held by the
+// returned vault for as long as it is in use.
+func InitPassword(i *VaultInput) (*Vault, error) {
+	return InitPasswordBytes(i, []byte(i.Password))
+}
+
+// InitPasswordBytes behaves like InitPassword but takes the password
+// as a byte slice, ignoring the Password field of i, so callers that
+// never want the key in an immutable string don't have to make one.
+// The vault keeps its own copy which means password can be wiped as
+// soon as this returns.
+func InitPasswordBytes(i *VaultInput, password []byte) (*Vault, error) {
+	v := newVault(i)
+	if len(password) == 0 {
+		return v, ErrMissingPassword
 	}
-	_, err = v.loadFromDisk()
+	v.password = copyBytes(password)
+	_, err := v.loadFromDisk(context.Background())
 	if err != nil {
-		log("Debug", "InitEnvVar(), error loading file from disk", "error", err.Error())
-		if detectFileNotFoundError(err) {
-			// create new file by writing nothing to it
-			log("Debug", "InitEnvVar(), attempting to create blank file")
-			err = v.Write("")
+		v.log("Debug", "InitPasswordBytes(), error loading file from disk", "error", err.Error())
+		if errors.Is(err, ErrVaultNotFound) {
+			err = nil
+			if i.CreateIfMissing {
+				v.log("Debug", "InitPasswordBytes(), attempting to create blank file")
+				err = v.Init()
+			}
+		}
+	}
+	return v, err
+}
+
+// obfuscationKey is the built-in key InitObfuscate vaults use. It
+// is published right here, which is the point: it hides contents
+// from casual eyes and nothing more.
+var obfuscationKey = []byte("uggsec obfuscation only, this key is not a secret")
+
+// InitObfuscate initializes a new or existing vault that uses the
+// same file format as every other vault but encrypts with a fixed
+// key built into this package instead of a password. It provides NO
+// SECURITY: anyone with this package can read the vault. It's meant
+// for non-sensitive contents that merely shouldn't be readable at a
+// glance, and for making that intent explicit rather than
+// hardcoding a password into a program. A warning is logged every
+// time it is used. Rotate returns ErrNotSupported for these vaults.
+func InitObfuscate(i *VaultInput) (*Vault, error) {
+	logTo(i.Logger, "Warn", "InitObfuscate(), vault uses a built-in key and provides no security", "file", i.Filename)
+	v, err := InitPasswordBytes(i, obfuscationKey)
+	v.obfuscate = true
+	return v, err
+}
+
+// InitMemory initializes a vault that keeps its encrypted contents
+// in memory instead of in a file, so nothing is ever written to
+// disk. Contents go through the same encryption as a file backed
+// vault so behavior matches, which makes this a drop-in for unit
+// tests and for short-lived secrets. If PasswordEnvVar is set the
+// password is read from that ENV var, otherwise a random password
+// is generated that only lives as long as the returned vault.
+// Filename, UseFileLock and the Service and User labels are ignored.
+func InitMemory(i *VaultInput) (*Vault, error) {
+	v := newVault(i)
+	v.filename = ""
+	v.fileLock = false
+	v.memory = true
+	if i.PasswordEnvVar != "" {
+		v.passwordEnvVar = i.PasswordEnvVar
+		_, err := v.getPassword()
+		if err != nil {
+			return v, err
+		}
+	} else {
+		password, err := v.newPassword()
+		if err != nil {
+			return v, err
 		}
+		v.password = []byte(password)
 	}
-	return &v, err
+	return v, v.Write("")
 }
 
-// NewPassword returns a password that can be used for interacting
-// with vaults. Since this package's password requirements are strict
-// this is a useful helper function when doing things like setting 
-// the contents of ENV vars on systems that don't support keyring.
-func NewVaultPassword() (string) {
-	rand.Seed(time.Now().UnixNano())
-	return(randStringRunes(keySize))
+// InitFS initializes a read-only vault whose encrypted contents are
+// read from Filename within fsys, such as an embed.FS that ships an
+// encrypted config inside the binary. Filename must be a valid
+// fs.FS path, slash separated and not rooted. The password comes
+// from Password or else PasswordEnvVar, the keyring isn't used.
+// Read and the other read methods work as usual while anything that
+// would modify the vault returns ErrReadOnly. Unlike the other Init
+// methods a missing file is an error, ErrVaultNotFound is returned
+// and CreateIfMissing is ignored.
+func InitFS(fsys fs.FS, i *VaultInput) (*Vault, error) {
+	v := newVault(i)
+	v.fsys = fsys
+	v.fileLock = false
+	v.keepBackup = false
+	if !fs.ValidPath(v.filename) {
+		return v, fmt.Errorf("invalid fs.FS path %q", v.filename)
+	}
+	if i.Password != "" {
+		v.password = []byte(i.Password)
+	} else {
+		v.passwordEnvVar = i.PasswordEnvVar
+	}
+	_, err := v.loadFromDisk(context.Background())
+	if err != nil {
+		v.log("Debug", "InitFS(), error loading file", "error", err.Error())
+	}
+	return v, err
 }
 
-// Write writes the contents of the input string into the 
+// Write writes the contents of the input string into the
 // filename associated with the vault and encrypts it using
 // the password retrieval mechanism available to the vault
 // (e.g., keyring or ENV var) then returns any errors it
 // encounters. It overrides the entire contents of the file.
-// If no file exists then one is created.
+// If no file exists then one is created. The new contents are
+// written to a temp file first and renamed over the vault file
+// so a failed write never leaves a truncated vault behind.
 func (v *Vault) Write(contents string) (err error) {
-	log("Debug", "Write(), getting password...")
-	password, err := v.getPassword()
+	defer wrapOp("write", v.filename, &err)
+	return v.WriteContext(context.Background(), contents)
+}
+
+// WriteContext behaves like Write but gives up and returns
+// ctx.Err() if ctx is cancelled or its deadline passes before
+// the password has been retrieved or the file has been written.
+func (v *Vault) WriteContext(ctx context.Context, contents string) (err error) {
+	defer wrapOp("write", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.writeBytes(ctx, []byte(contents))
+}
+
+// WriteBytes behaves like Write but takes the contents as raw
+// bytes so binary data (e.g., serialized protobufs or key
+// material) can be stored without converting it to a string.
+func (v *Vault) WriteBytes(b []byte) (err error) {
+	defer wrapOp("write", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.writeBytes(context.Background(), b)
+}
+
+// WriteIfAbsent behaves like Write but only stores contents if the
+// vault has no file yet, for first-run initialization that mustn't
+// clobber secrets left by an earlier run. The file is written under a
+// temp name and hard linked into place rather than renamed, so of
+// several writers racing in this or other processes exactly one
+// succeeds, a crash never leaves a partial vault file behind,
+// and UseFileLock additionally keeps it from overlapping a locked
+// Write. It reports whether contents were written, false with a nil
+// error means a file already existed, even an empty one, and was
+// left untouched.
+func (v *Vault) WriteIfAbsent(contents string) (written bool, err error) {
+	defer wrapOp("write", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.observer != nil {
+		defer v.observeWrite(time.Now(), &err)
+	}
+	if err := v.writable(); err != nil {
+		return false, err
+	}
+	// skip the key derivation when the answer is already known, the
+	// link in createRaw is what actually decides
+	if _, err := v.statRaw(); err == nil {
+		v.log("Debug", "WriteIfAbsent(), vault already exists", "file", v.filename)
+		return false, nil
+	}
+	h, b, err := v.wrapPlainText(newHeader(algAES256GCM), []byte(contents))
+	if err != nil {
+		return false, err
+	}
+	encrypted, err := v.sealForWrite(context.Background(), h, b, v.associatedData)
+	if err != nil {
+		return false, err
+	}
+	unlock, err := v.lockFile()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	v.log("Debug", "WriteIfAbsent(), creating file...")
+	return v.createRaw([]byte(encrypted))
+}
+
+func (v *Vault) writeBytes(ctx context.Context, b []byte) (err error) {
+	h, b, err := v.wrapPlainText(newHeader(algAES256GCM), b)
 	if err != nil {
 		return err
 	}
-	log("Debug", "Write(), encryping message...")
-	encrypted, err := encrypt(contents, password)
+	return v.writeBytesHeader(ctx, h, b, v.associatedData)
+}
+
+// writeBytesHeader behaves like writeBytes but seals b under the
+// given header and associated data
+func (v *Vault) writeBytesHeader(ctx context.Context, h header, b, ad []byte) (err error) {
+	if v.observer != nil {
+		defer v.observeWrite(time.Now(), &err)
+	}
+	encrypted, err := v.sealForWrite(ctx, h, b, ad)
 	if err != nil {
 		return err
 	}
-	b := []byte(encrypted)
-	log("Debug", "Write(), writing file...")
-	err = ioutil.WriteFile(v.filename, b, 0600)
-	return err
+	return v.storeEncrypted(encrypted)
 }
 
+// WriteDryRun does everything Write would except store the result,
+// returning the encrypted contents that would have been written.
+// It fails the same way Write would, ErrReadOnly for InitFS vaults
+// for example, which makes it useful for checking that encryption
+// works before committing to a write. Unless Deterministic is set
+// the result differs on every call so golden file tests should
+// decrypt it rather than compare it.
+func (v *Vault) WriteDryRun(contents string) (ciphertext string, err error) {
+	defer wrapOp("write", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	h, b, err := v.wrapPlainText(newHeader(algAES256GCM), []byte(contents))
+	if err != nil {
+		return "", err
+	}
+	return v.sealForWrite(context.Background(), h, b, v.associatedData)
+}
+
+// sealForWrite is the part of writeBytesHeader that comes before
+// anything is stored
+func (v *Vault) sealForWrite(ctx context.Context, h header, b, ad []byte) (encrypted string, err error) {
+	if err := v.writable(); err != nil {
+		return "", err
+	}
+	encrypted, err = v.sealHeader(ctx, h, b, ad)
+	if err != nil {
+		return "", err
+	}
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+	return encrypted, nil
+}
+
+// storeEncrypted writes already encrypted contents to the vault's
+// storage, holding the file lock if the vault uses one
+func (v *Vault) storeEncrypted(encrypted string) error {
+	unlock, err := v.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	v.log("Debug", "storeEncrypted(), writing file...")
+	return v.writeRaw([]byte(encrypted))
+}
 
 // Read returns the decrypted contents of the filename
 // associated with the vault using whatever password
-// retreival mechanisms are avaialble to the vault 
-// (e.g., keyring or ENV var)
+// retreival mechanisms are avaialble to the vault
+// (e.g., keyring or ENV var). If the contents fail
+// authentication then ErrTampered is returned instead
+// of the corrupted data. If the file doesn't exist then
+// ErrVaultNotFound is returned.
 func (v *Vault) Read() (contents string, err error) {
-	return v.loadFromDisk()
+	defer wrapOp("read", v.filename, &err)
+	return v.ReadContext(context.Background())
+}
+
+// ReadContext behaves like Read but gives up and returns
+// ctx.Err() if ctx is cancelled or its deadline passes before
+// the password has been retrieved or the file has been read.
+// This guards against keyring backends that can hang, such as
+// some Linux secret-service setups.
+func (v *Vault) ReadContext(ctx context.Context) (contents string, err error) {
+	defer wrapOp("read", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	b, err := v.loadFromDisk(ctx)
+	if err == nil && v.validateUTF8 && !utf8.Valid(b) {
+		return "", ErrInvalidUTF8
+	}
+	return string(b), err
+}
+
+// ReadBytes behaves like Read but returns the decrypted
+// contents as raw bytes exactly as they were passed to
+// WriteBytes, including any NUL or non-UTF8 sequences.
+func (v *Vault) ReadBytes() (contents []byte, err error) {
+	defer wrapOp("read", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.loadFromDisk(context.Background())
+}
+
+// Encrypt returns contents encrypted with the vault's password in
+// the same encoded form Write stores on disk, without touching the
+// vault's file. This is useful for shipping encrypted blobs over a
+// network or storing them in a database instead of a file.
+func (v *Vault) Encrypt(contents string) (ciphertext string, err error) {
+	defer wrapOp("encrypt", v.filename, &err)
+	return v.seal(context.Background(), []byte(contents))
 }
 
-func (v *Vault) getPasswordEnv() (password string, err error) {
-	password = os.Getenv(v.passwordEnvVar)
+// Decrypt reverses Encrypt using the vault's password. Like Read it
+// returns ErrTampered if the ciphertext fails authentication.
+func (v *Vault) Decrypt(ciphertext string) (contents string, err error) {
+	defer wrapOp("decrypt", v.filename, &err)
+	b, err := v.open(context.Background(), ciphertext, v.associatedData)
+	return string(b), err
+}
+
+// Seal encrypts plaintext with a key derived from password, without
+// any vault, keyring or file involved. The result is in the encoded
+// form Write stores, a format header followed by the scrypt salt,
+// nonce and AES-256-GCM ciphertext, so it can be kept anywhere that
+// holds a string. Vaults encrypt their contents the same way.
+func Seal(plaintext []byte, password string) (string, error) {
 	if password == "" {
-		msg := fmt.Sprintf("no password found in %s env var", v.passwordEnvVar)
-		err = errors.New(msg)
+		return "", ErrMissingPassword
 	}
-	return password, err
+	return encrypt(plaintext, []byte(password))
 }
 
-func (v *Vault) getPasswordKeyring() (password string, err error) {
-	return(keyring.Get(v.service, v.user))
+// Open reverses Seal, and also decrypts anything a vault encrypted
+// with the same password and no AssociatedData, such as the output
+// of Vault.Encrypt. ErrTampered is returned if the ciphertext fails
+// authentication.
+func Open(ciphertext string, password string) ([]byte, error) {
+	if password == "" {
+		return nil, ErrMissingPassword
+	}
+	return decrypt(ciphertext, []byte(password), nil)
 }
 
+// Migrate upgrades a vault written by older releases of this package,
+// which used AES-CFB with a fixed IV and the raw password as the key,
+// to the current authenticated format. The legacy contents are
+// decrypted with the vault's password and rewritten atomically. A
+// vault that is already in the current format is left untouched so
+// Migrate is safe to call on every startup. With AllowLegacy set
+// Read and the methods built on it also decrypt legacy files so
+// nothing breaks before Migrate has run, but such files have no
+// authentication and keep their weak encryption until they're
+// migrated.
+func (v *Vault) Migrate() (err error) {
+	defer wrapOp("migrate", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.writable(); err != nil {
+		return err
+	}
+	data, err := v.readRaw()
+	if err != nil {
+		return err
+	}
+	if hasHeader(string(data)) {
+		v.log("Debug", "Migrate(), vault already in current format")
+		return nil
+	}
+	// the legacy format never had a secondary password
+	password, err := v.getPrimaryPassword()
+	if err != nil {
+		return err
+	}
+	defer wipe(password)
+	v.log("Debug", "Migrate(), decrypting legacy contents...")
+	contents, err := decryptLegacy(string(data), password)
+	if err != nil {
+		return err
+	}
+	v.log("Debug", "Migrate(), rewriting in current format...")
+	return v.writeBytes(context.Background(), contents)
+}
 
-func (v *Vault) getPassword() (password string, err error) {
+// CopyTo decrypts the vault's contents and writes them into dest,
+// encrypted with dest's password mechanism and stored in dest's
+// file. This moves secrets between machines or mechanisms, such as
+// exporting a keyring vault to a portable ENV var one. Settings the
+// contents were written with, like an expiry from WriteWithTTL, are
+// carried over. The source is only read. The destination is written
+// atomically so a failed copy never leaves a partial file behind.
+func (v *Vault) CopyTo(dest *Vault) (err error) {
+	defer wrapOp("copy", v.filename, &err)
+	if err := dest.writable(); err != nil {
+		return err
+	}
+	v.mu.Lock()
+	data, err := v.readRaw()
+	if err != nil {
+		v.mu.Unlock()
+		return err
+	}
+	password, err := v.getPassword()
+	v.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	defer wipe(password)
+	v.log("Debug", "CopyTo(), decrypting source contents...")
+	h, plainText, err := decryptHeader(string(data), password, v.associatedData)
+	if err != nil {
+		return err
+	}
+	h.version = formatVersion
+	h, err = dest.applySettings(h)
+	if err != nil {
+		return err
+	}
+	dest.mu.Lock()
+	defer dest.mu.Unlock()
+	v.log("Debug", "CopyTo(), writing destination...", "file", dest.filename)
+	return dest.writeBytesHeader(context.Background(), h, plainText, dest.associatedData)
+}
+
+// Append adds contents to the end of whatever the vault already
+// holds and writes the result back encrypted, atomically like
+// Write. A vault with no file yet is treated as empty.
+func (v *Vault) Append(contents string) (err error) {
+	defer wrapOp("append", v.filename, &err)
+	return v.AppendWithSeparator(contents, "")
+}
+
+// AppendWithSeparator behaves like Append but puts sep between the
+// existing contents and the new ones, for example "\n" to treat
+// the vault as a log. The separator is left out when the vault is
+// empty so the first entry doesn't start with one.
+func (v *Vault) AppendWithSeparator(contents, sep string) (err error) {
+	defer wrapOp("append", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.writable(); err != nil {
+		return err
+	}
+	existing, err := v.loadFromDisk(context.Background())
+	if err != nil && !errors.Is(err, ErrVaultNotFound) {
+		return err
+	}
+	if len(existing) > 0 {
+		existing = append(existing, sep...)
+	}
+	return v.writeBytes(context.Background(), append(existing, contents...))
+}
+
+// Delete removes the vault's file from disk and, for keyring
+// backed vaults, deletes the password stored in the OS keyring.
+// A file or keyring secret that is already gone is not treated as
+// an error. Both steps are always attempted and if either fails
+// the returned error describes every failure.
+func (v *Vault) Delete() (err error) {
+	defer wrapOp("delete", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.writable(); err != nil {
+		return err
+	}
+	var msgs []string
+	v.log("Debug", "Delete(), removing file...")
+	err = v.removeRaw()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		msgs = append(msgs, fmt.Sprintf("removing vault file: %v", err))
+	}
 	if v.keyring {
-		password, err = v.getPasswordKeyring()
-	} else {
+		v.log("Debug", "Delete(), deleting keyring secret...")
+		v.pwCache.clear()
+		err = v.ring.Delete(v.service, v.user)
+		if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			msgs = append(msgs, fmt.Sprintf("deleting keyring secret: %v", err))
+		}
+		err = v.ring.Delete(v.service, v.user+rotatedAtSuffix)
+		if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			msgs = append(msgs, fmt.Sprintf("deleting keyring rotation time: %v", err))
+		}
+	}
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// Init creates an empty encrypted vault if the vault's file doesn't
+// exist yet and leaves an existing one untouched, whichever password
+// mechanism the vault uses. The Init functions only do this on their
+// own when CreateIfMissing is set.
+func (v *Vault) Init() (err error) {
+	defer wrapOp("init", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	_, err = v.statRaw()
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	v.log("Debug", "Init(), creating empty vault", "file", v.filename)
+	return v.writeBytes(context.Background(), nil)
+}
+
+// Exists reports whether the vault's file is present on disk.
+// A file that exists but holds no contents (such as the one
+// created by Init) still counts as existing, use IsEmpty to tell
+// the two apart.
+func (v *Vault) Exists() (exists bool, err error) {
+	defer wrapOp("stat", v.filename, &err)
+	_, err = v.statRaw()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// IsEmpty reports whether the vault holds no contents. This is
+// the case when the file does not exist, is zero bytes, or
+// decrypts to an empty string. Any other error encountered
+// while reading the vault is returned.
+func (v *Vault) IsEmpty() (empty bool, err error) {
+	defer wrapOp("stat", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	size, err := v.statRaw()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return true, nil
+		}
+		return false, err
+	}
+	if size == 0 {
+		return true, nil
+	}
+	contents, err := v.loadFromDisk(context.Background())
+	if err != nil {
+		return false, err
+	}
+	return len(contents) == 0, nil
+}
+
+// Size returns the length of the vault's decrypted contents and of
+// the file holding them, for enforcing quotas. The ciphertext size
+// only needs a stat, the plaintext size means decrypting, which for
+// files written by WriteStream is done in small chunks and counted
+// without holding the contents in memory. A vault with no file
+// gives ErrVaultNotFound.
+func (v *Vault) Size() (plaintext int, ciphertext int, err error) {
+	defer wrapOp("stat", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	size, err := v.statRaw()
+	if err != nil {
+		return 0, 0, notFoundError(err)
+	}
+	if size == 0 {
+		return 0, 0, nil
+	}
+	if v.usesOSFiles() {
+		rc, err := v.openStream()
+		if err == nil {
+			defer rc.Close()
+			n, err := io.Copy(io.Discard, rc)
+			return int(n), int(size), err
+		}
+		if err != errNotStream && !errors.Is(err, ErrLegacyFormat) {
+			return 0, int(size), err
+		}
+	}
+	contents, err := v.loadFromDisk(context.Background())
+	if err != nil {
+		return 0, int(size), err
+	}
+	defer wipe(contents)
+	return len(contents), int(size), nil
+}
+
+// VerifyPassword reports whether the vault's password is the one
+// its contents were written with, by decrypting them and checking
+// the authentication tag. A vault that has nothing stored yet has
+// no password to disagree with so it verifies as true. Errors other
+// than a failed authentication, such as the password not being
+// retrievable at all, are returned.
+func (v *Vault) VerifyPassword() (ok bool, err error) {
+	defer wrapOp("verify password", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	size, err := v.statRaw()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return true, nil
+		}
+		return false, err
+	}
+	if size == 0 {
+		return true, nil
+	}
+	_, err = v.loadFromDisk(context.Background())
+	if errors.Is(err, ErrTampered) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Rotate re-keys the vault. The current contents are decrypted
+// with the existing password, then re-encrypted with newPassword
+// (or a freshly generated one if newPassword is empty) which is
+// stored using the vault's password mechanism. For keyring vaults
+// the keyring secret is replaced, for ENV var vaults the variable
+// is only updated for the current process so callers must persist
+// the new value themselves.
+//
+// The re-encrypted contents are written to a temp file next to the
+// vault and renamed into place so the vault file is never left half
+// written. If the rename fails the old password is restored. See
+// RegeneratePassword for replacing only the keyring secret.
+func (v *Vault) Rotate(newPassword string) (err error) {
+	defer wrapOp("rotate", v.filename, &err)
+	return v.RotateBytes([]byte(newPassword))
+}
+
+// RotateBytes behaves like Rotate but takes the new password as a
+// byte slice, which the caller is free to wipe once it returns.
+func (v *Vault) RotateBytes(newPassword []byte) (err error) {
+	defer wrapOp("rotate", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.observer != nil {
+		defer v.observeWrite(time.Now(), &err)
+	}
+	if err := v.writable(); err != nil {
+		return err
+	}
+	if v.obfuscate {
+		return fmt.Errorf("%w: obfuscation vaults have no password to rotate", ErrNotSupported)
+	}
+	if len(newPassword) == 0 {
+		generated, err := v.newPassword()
+		if err != nil {
+			return err
+		}
+		newPassword = []byte(generated)
+	}
+	if (v.usesEnvVar() || v.passwordFile != "") && len(newPassword) < minPasswordLength {
+		// getPasswordEnv or filePassword would refuse it on the next read
+		return fmt.Errorf("%w: new password has %d characters, need at least %d",
+			ErrWeakPassword, len(newPassword), minPasswordLength)
+	}
+	oldPrimary, err := v.getPrimaryPassword()
+	if err != nil {
+		return err
+	}
+	defer wipe(oldPrimary)
+	oldPassword, err := v.withSecondary(oldPrimary)
+	if err != nil {
+		return err
+	}
+	defer wipeUnlessSame(oldPrimary, oldPassword)
+	newKey, err := v.withSecondary(newPassword)
+	if err != nil {
+		return err
+	}
+	defer wipeUnlessSame(newPassword, newKey)
+	v.log("Debug", "Rotate(), reading contents with old password...")
+	data, err := v.readRaw()
+	if err != nil {
+		return err
+	}
+	// work on the plaintext exactly as sealed, flags included, so
+	// that something like an expiry survives the rotation
+	h, plainText, err := decryptHeader(string(data), oldPassword, v.associatedData)
+	if err != nil {
+		return err
+	}
+	h.version = formatVersion
+	h, err = v.applySettings(h)
+	if err != nil {
+		return err
+	}
+	v.log("Debug", "Rotate(), encrypting contents with new password...")
+	encrypted, err := v.encryptWith(h, plainText, newKey, v.associatedData)
+	if err != nil {
+		return err
+	}
+	if v.memory {
+		// the old contents have to stay in place until the new
+		// password is, otherwise a failed set loses them both
+		err = v.setPassword(newPassword)
+		if err != nil {
+			return err
+		}
+		v.dropCache()
+		v.memData = []byte(encrypted)
+		return nil
+	}
+	unlock, err := v.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	tmpName, err := writeTempFile(v.filename, []byte(encrypted), v.fileMode)
+	if err != nil {
+		return err
+	}
+	v.log("Debug", "Rotate(), storing new password...")
+	err = v.setPassword(newPassword)
+	if err != nil {
+		fileBackend.Remove(tmpName)
+		return err
+	}
+	v.log("Debug", "Rotate(), replacing vault file...")
+	err = v.replaceVaultFile(tmpName)
+	if err != nil {
+		if rerr := v.setPassword(oldPrimary); rerr != nil {
+			return fmt.Errorf("%v (restoring old password also failed: %v)", err, rerr)
+		}
+		return err
+	}
+	v.recordRotation()
+	return v.updateChecksum()
+}
+
+// RegeneratePassword replaces a keyring vault's keyring secret with
+// a freshly generated password and returns it, leaving the vault
+// file alone. Unlike Rotate this does not re-encrypt anything: once
+// it returns the existing file can no longer be read with the
+// keyring secret and stays unreadable until it is re-encrypted with
+// the returned password, for example on another machine. It's meant
+// for split workflows where the key and the file are managed
+// separately, Rotate is the safe choice otherwise.
+func (v *Vault) RegeneratePassword() (password string, err error) {
+	defer wrapOp("regenerate password", v.filename, &err)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.writable(); err != nil {
+		return "", err
+	}
+	if !v.keyring {
+		return "", fmt.Errorf("%w: only keyring vaults can regenerate their password, the %s mechanism can't",
+			ErrNotSupported, v.Mechanism())
+	}
+	password, err = v.newPassword()
+	if err != nil {
+		return "", err
+	}
+	v.log("Warn", "RegeneratePassword(), replacing keyring secret without re-encrypting the vault file",
+		"filename", v.filename)
+	if err := v.setPassword([]byte(password)); err != nil {
+		return "", err
+	}
+	v.dropCache()
+	v.recordRotation()
+	return password, nil
+}
+
+// writeFileAtomic writes data to filename by way of a temp file
+// in the same directory that is then renamed into place. Readers
+// see either the old contents or the new ones, never a mix. With
+// durable set the write is flushed to disk as well, see
+// replaceFileDurable.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode, durable bool) (err error) {
+	tmpName, err := writeTempFile(filename, data, perm)
+	if err != nil {
+		return err
+	}
+	return replaceFileDurable(tmpName, filename, perm, durable)
+}
+
+// replaceFileDurable behaves like replaceFile, and when durable is
+// set also syncs tmpName before the rename and the directory after
+// it. Without the first sync a crash can persist the rename before
+// the data, leaving an empty vault file, without the second the
+// rename itself can be lost.
+func replaceFileDurable(tmpName, filename string, perm os.FileMode, durable bool) (err error) {
+	if !durable {
+		return replaceFile(tmpName, filename, perm)
+	}
+	err = fileBackend.Sync(tmpName)
+	if err != nil {
+		fileBackend.Remove(tmpName)
+		return err
+	}
+	err = replaceFile(tmpName, filename, perm)
+	if err != nil {
+		return err
+	}
+	return syncDir(filename)
+}
+
+// replaceFile renames tmpName over filename. Renaming can't work
+// when filename sits on a different filesystem than its directory
+// (e.g., a file bind mounted into a container) so in that case it
+// falls back to copying the temp file's contents into filename
+// directly. The temp file is always cleaned up.
+func replaceFile(tmpName, filename string, perm os.FileMode) (err error) {
+	err = fileBackend.Rename(tmpName, filename)
+	if err == nil {
+		return nil
+	}
+	defer fileBackend.Remove(tmpName)
+	if errors.Is(err, syscall.EXDEV) || errors.Is(err, syscall.EBUSY) {
+		log("Debug", "replaceFile(), rename not possible, writing in place", "error", err.Error())
+		return copyFile(tmpName, filename, perm)
+	}
+	return err
+}
+
+func copyFile(src, dst string, perm os.FileMode) (err error) {
+	in, err := fileBackend.OpenFile(src, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := fileBackend.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		// perm only applies when the file is created so set it
+		// explicitly in case dst already existed
+		err = out.Chmod(perm)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// createTempFile opens a new temp file in the same directory as
+// filename. Keeping it in the same directory means it can be
+// renamed over filename without crossing filesystems. Like
+// ioutil.TempFile the file is created with O_EXCL and mode 0600, and
+// a name that is already taken is retried with another.
+func createTempFile(filename string) (f file, err error) {
+	for try := 0; try < 10; try++ {
+		var tmpName string
+		tmpName, err = tempName(filename)
+		if err != nil {
+			return nil, err
+		}
+		f, err = fileBackend.OpenFile(tmpName, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if !errors.Is(err, os.ErrExist) {
+			break
+		}
+	}
+	return f, err
+}
+
+// writeTempFile writes data to a new temp file next to filename
+// and returns the temp file's name. The name is random, like the
+// ones createTempFile picks, and a name that is already taken is
+// retried with another.
+func writeTempFile(filename string, data []byte, perm os.FileMode) (tmpName string, err error) {
+	for try := 0; try < 10; try++ {
+		tmpName, err = tempName(filename)
+		if err != nil {
+			return "", err
+		}
+		err = fileBackend.WriteFile(tmpName, data, perm)
+		if !errors.Is(err, os.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		if !errors.Is(err, os.ErrExist) {
+			// don't leave a partial write behind
+			fileBackend.Remove(tmpName)
+		}
+		return "", err
+	}
+	return tmpName, nil
+}
+
+// tempName returns a random temp file name in the same directory as
+// filename
+func tempName(filename string) (string, error) {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	b := make([]byte, 8)
+	_, err := io.ReadFull(crand.Reader, b)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "."+base+".tmp-"+hex.EncodeToString(b)), nil
+}
+
+// getPasswordEnv reads the password from the vault's ENV var. An
+// unset or empty variable is reported as ErrMissingPassword while one
+// that is set but too short to be a reasonable passphrase is reported
+// as ErrWeakPassword, so users get told up front rather than ending
+// up with a vault that is trivial to brute force.
+func (v *Vault) getPasswordEnv() (password []byte, err error) {
+	return envPassword(v.passwordEnvVar, v.envEncoding)
+}
+
+// envPassword reads a password written with encoding e from the
+// named ENV var with the checks described on getPasswordEnv
+func envPassword(name string, e PasswordEncoding) (password []byte, err error) {
+	password = []byte(os.Getenv(name))
+	if len(password) == 0 {
+		return nil, fmt.Errorf("%w: %s env var is not set", ErrMissingPassword, name)
+	}
+	password, err = e.decode(name, password)
+	if err != nil {
+		return nil, err
+	}
+	if len(password) < minPasswordLength {
+		return nil, fmt.Errorf("%w: %s env var holds %d characters, need at least %d (see NewVaultPassword)",
+			ErrWeakPassword, name, len(password), minPasswordLength)
+	}
+	return password, nil
+}
+
+// Mechanism identifies where a vault's password comes from. Its
+// values are plain strings so they can be shown to users or logged
+// as is.
+type Mechanism string
+
+const (
+	// MechanismKeyring means the password is kept in the OS keyring
+	MechanismKeyring Mechanism = "keyring"
+
+	// MechanismEnvVar means the password is read from an ENV var
+	MechanismEnvVar Mechanism = "envvar"
+
+	// MechanismPassword means the vault holds the password itself,
+	// either from VaultInput.Password or generated by InitMemory
+	MechanismPassword Mechanism = "password"
+
+	// MechanismObfuscate means the vault was created by
+	// InitObfuscate and uses a built-in key, so it isn't secret
+	MechanismObfuscate Mechanism = "obfuscate"
+
+	// MechanismFile means the password is read from PasswordFile
+	MechanismFile Mechanism = "file"
+)
+
+// Mechanism reports where the vault's password actually comes from.
+// After InitSmart this reflects any fallback that happened rather
+// than the mechanism that was asked for.
+func (v *Vault) Mechanism() Mechanism {
+	switch {
+	case v.keyring:
+		return MechanismKeyring
+	case v.obfuscate:
+		return MechanismObfuscate
+	case len(v.password) > 0:
+		return MechanismPassword
+	case v.passwordFile != "":
+		return MechanismFile
+	}
+	return MechanismEnvVar
+}
+
+// usesEnvVar reports whether the vault's password comes from an ENV var
+func (v *Vault) usesEnvVar() bool {
+	return !v.keyring && len(v.password) == 0 && v.passwordFile == ""
+}
+
+// getPassword returns the password the vault's contents are
+// encrypted with, combining the primary password with the secondary
+// one when dual control is on. The slice is always a fresh copy that
+// the caller owns and should wipe once done with it.
+func (v *Vault) getPassword() (password []byte, err error) {
+	return v.getPasswordContext(context.Background())
+}
+
+// getPasswordContext runs getPassword but stops waiting on a keyring
+// lookup as soon as ctx is done, see getPasswordKeyringContext
+func (v *Vault) getPasswordContext(ctx context.Context) (password []byte, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	primary, err := v.getPrimaryPasswordContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	password, err = v.withSecondary(primary)
+	wipeUnlessSame(password, primary)
+	return password, err
+}
+
+// getPrimaryPassword returns the password from the vault's keyring,
+// explicit password or ENV var, the one Rotate replaces. Like
+// getPassword the result is a copy owned by the caller. Whatever the
+// mechanism, an empty password is reported as ErrMissingPassword
+// here rather than left to fail somewhere inside the cipher.
+func (v *Vault) getPrimaryPassword() (password []byte, err error) {
+	return v.getPrimaryPasswordContext(context.Background())
+}
+
+// getPrimaryPasswordContext is getPrimaryPassword with a keyring
+// lookup that gives up once ctx is done. The cache is only filled
+// here, by a caller holding the vault's lock, so an abandoned lookup
+// can never put back a password that Rotate has since replaced.
+func (v *Vault) getPrimaryPasswordContext(ctx context.Context) (password []byte, err error) {
+	if v.passwordTTL > 0 {
+		if password, ok := v.pwCache.get(v.nowFunc()); ok {
+			return password, nil
+		}
+	}
+	switch {
+	case v.keyring:
+		var s string
+		s, err = v.getPasswordKeyringContext(ctx)
+		password = []byte(s)
+	case len(v.password) > 0:
+		password = copyBytes(v.password)
+	case v.passwordFile != "":
+		password, err = filePassword(v.passwordFile)
+	default:
 		password, err = v.getPasswordEnv()
 	}
+	if err == nil && len(password) == 0 {
+		err = fmt.Errorf("%w: %s password is empty", ErrMissingPassword, v.Mechanism())
+	}
+	if err == nil && v.passwordTTL > 0 && len(v.password) == 0 {
+		v.pwCache.put(password, v.nowFunc().Add(v.passwordTTL))
+	}
 	return password, err
 }
 
-func (v *Vault) loadFromDisk() (contents string, err error) {
-	data, err := ioutil.ReadFile(v.filename)
+// withSecondary combines primary with the secondary password when
+// SecondaryPasswordEnvVar was set and returns primary unchanged
+// otherwise. The primary password's length is included so that no
+// two different pairs of passwords combine into the same string.
+// A combined result is a new slice and primary is left untouched.
+func (v *Vault) withSecondary(primary []byte) (password []byte, err error) {
+	if v.secondaryVar == "" {
+		return primary, nil
+	}
+	secondary, err := envPassword(v.secondaryVar, PasswordEncodingRaw)
 	if err != nil {
-		return contents, err
+		return nil, err
 	}
-	password, err := v.getPassword()
+	defer wipe(secondary)
+	password = append([]byte(strconv.Itoa(len(primary))+":"), primary...)
+	return append(password, secondary...), nil
+}
+
+// wipeUnlessSame wipes b unless it is the same slice as keep, which
+// is how withSecondary hands back primary when there's no secondary
+func wipeUnlessSame(keep, b []byte) {
+	if len(keep) > 0 && len(b) > 0 && &keep[0] == &b[0] {
+		return
+	}
+	wipe(b)
+}
+
+// copyBytes returns a copy of b that doesn't share its backing array
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
+
+func (v *Vault) setPassword(password []byte) (err error) {
+	v.pwCache.clear()
+	switch {
+	case v.keyring:
+		return v.ring.Set(v.service, v.user, string(password))
+	case len(v.password) > 0:
+		wipe(v.password)
+		v.password = copyBytes(password)
+		return nil
+	case v.passwordFile != "":
+		return writeFileAtomic(v.passwordFile, password, defaultFileMode, v.durable)
+	}
+	return os.Setenv(v.passwordEnvVar, v.envEncoding.encode(password))
+}
+
+func (v *Vault) loadFromDisk(ctx context.Context) (contents []byte, err error) {
+	defer wrapOp("read", v.filename, &err)
+	if v.observer != nil {
+		defer v.observeRead(time.Now(), &err)
+	}
+	if contents, ok := v.cachedRead(); ok {
+		return contents, nil
+	}
+	data, err := v.readRaw()
 	if err != nil {
 		return contents, err
 	}
-	return decrypt(string(data), password)
+	contents, err = v.openStored(ctx, data)
+	if err == nil {
+		v.storeCache(data, contents)
+	}
+	if errors.Is(err, ErrExpired) && v.deleteExpired && !v.readOnly {
+		v.log("Debug", "loadFromDisk(), removing expired vault...")
+		if rerr := v.removeRaw(); rerr != nil {
+			v.log("Warn", "loadFromDisk(), removing expired vault failed", "error", rerr.Error())
+		}
+	}
+	return contents, err
+}
+
+// openStored decrypts data as read from a vault file, falling back
+// to the legacy format for headerless files when AllowLegacy is set
+func (v *Vault) openStored(ctx context.Context, data []byte) (contents []byte, err error) {
+	contents, err = v.open(ctx, string(data), v.associatedData)
+	if errors.Is(err, ErrLegacyFormat) {
+		contents, err = v.openLegacy(string(data))
+	}
+	return contents, err
 }
 
+// seal encrypts b with the vault's password into the encoded form
+// that is stored on disk
+func (v *Vault) seal(ctx context.Context, b []byte) (encrypted string, err error) {
+	h, b, err := v.wrapPlainText(newHeader(algAES256GCM), b)
+	if err != nil {
+		return "", err
+	}
+	return v.sealHeader(ctx, h, b, v.associatedData)
+}
+
+// sealHeader behaves like seal but writes the given header and
+// binds the ciphertext to ad
+func (v *Vault) sealHeader(ctx context.Context, h header, b, ad []byte) (encrypted string, err error) {
+	v.log("Debug", "seal(), getting password...")
+	password, err := v.getPasswordContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer wipe(password)
+	v.log("Debug", "seal(), encryping message...")
+	return v.encryptWith(h, b, password, ad)
+}
+
+// open decrypts the encoded form produced by seal, which must have
+// been bound to the associated data ad
+func (v *Vault) open(ctx context.Context, encrypted string, ad []byte) (contents []byte, err error) {
+	password, err := v.getPasswordContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(password)
+	h, plainText, err := decryptHeader(encrypted, password, ad)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapPlainText(h, plainText, v.nowFunc())
+}
 
 func encode(b []byte) string {
 	return base64.StdEncoding.EncodeToString(b)
 }
 
-func decode(s string) []byte {
+func decode(s string) ([]byte, error) {
 	data, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("decoding vault contents: %w", err)
+	}
+	return data, nil
+}
+
+// deriveKey stretches an arbitrary length password into keyLen
+// bytes of key material using scrypt and the provided salt
+func deriveKey(password, salt []byte, keyLen int, kdf KDFParams) ([]byte, error) {
+	kdf = kdf.withDefaults()
+	return scrypt.Key(password, salt, kdf.N, kdf.R, kdf.P, keyLen)
+}
+
+// newAEAD derives the key for password and salt and returns the
+// AEAD used to seal and open vault contents under header h, which
+// names the algorithm, key size and KDF parameters
+func newAEAD(h header, password, salt []byte) (cipher.AEAD, error) {
+	key, err := deriveKey(password, salt, contentKeySize(h.algorithm), h.kdf)
+	if err != nil {
+		return nil, err
 	}
-	return data
+	// the cipher keeps its own copy of the key so the derived bytes
+	// can be wiped straight away
+	defer wipe(key)
+	return aeadForKey(h.algorithm, key)
+}
+
+// newAESGCM derives a keyLen byte AES key for password and salt and
+// returns the AEAD for it
+func newAESGCM(password, salt []byte, keyLen int, kdf KDFParams) (cipher.AEAD, error) {
+	key, err := deriveKey(password, salt, keyLen, kdf)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(key)
+	return aeadForKey(algAES256GCM, key)
+}
+
+// encrypt seals plainText with a key derived from password. The
+// result is the format header followed by the encoded salt, nonce
+// then ciphertext so that everything needed to decrypt besides the
+// password is in the file.
+func encrypt(plainText, password []byte) (string, error) {
+	return encryptHeader(crand.Reader, newHeader(algAES256GCM), plainText, password, nil)
 }
 
-func encrypt(text, password string) (string, error) {
-	block, err := aes.NewCipher([]byte(password))
+// encryptHeader behaves like encrypt but writes the given header,
+// whose flags describe how plainText was put together, and binds
+// the ciphertext to the associated data ad. The salt and nonce are
+// read from rand.
+func encryptHeader(rand io.Reader, h header, plainText, password, ad []byte) (string, error) {
+	salt := make([]byte, saltSize)
+	_, err := io.ReadFull(rand, salt)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(h, password, salt)
 	if err != nil {
 		return "", err
 	}
-	plainText := []byte(text)
-	cfb := cipher.NewCFBEncrypter(block, bytes)
-	cipherText := make([]byte, len(plainText))
-	cfb.XORKeyStream(cipherText, plainText)
-	return encode(cipherText), nil
+	return sealAEAD(rand, h, aead, salt, plainText, ad)
 }
 
-func decrypt(encrypted, password string) (string, error) {
-	block, err := aes.NewCipher([]byte(password))
+// sealAEAD seals plainText with aead, whose key was derived from
+// salt, and returns it with the header, salt and nonce in front. The
+// nonce is read from rand.
+func sealAEAD(rand io.Reader, h header, aead cipher.AEAD, salt, plainText, ad []byte) (string, error) {
+	// a fresh nonce for every message so the same key never
+	// reuses a keystream
+	nonce := make([]byte, aead.NonceSize())
+	_, err := io.ReadFull(rand, nonce)
 	if err != nil {
 		return "", err
 	}
-	cipherText := decode(encrypted)
-	cfb := cipher.NewCFBDecrypter(block, bytes)
-	plainText := make([]byte, len(cipherText))
-	cfb.XORKeyStream(plainText, cipherText)
-	return string(plainText), nil
+	prefix := append(append([]byte(nil), salt...), nonce...)
+	cipherText := aead.Seal(prefix, nonce, plainText, h.aad(ad))
+	return h.String() + h.encodePayload(cipherText), nil
 }
 
-func initKeyring(service, user string) (err error) {
-	err = keyring.Set(service, user, NewVaultPassword())
-	return err
+// decrypt reads the header on encrypted, hands the payload to the
+// routine for the algorithm it names and then undoes whatever the
+// header's flags say was done to the plaintext before sealing
+func decrypt(encrypted string, password, ad []byte) ([]byte, error) {
+	h, plainText, err := decryptHeader(encrypted, password, ad)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapPlainText(h, plainText, time.Now())
 }
 
-func randStringRunes(n int) string {
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letterRunes[rand.Intn(len(letterRunes))]
+// decryptHeader returns the header of encrypted along with the
+// plaintext exactly as it was sealed, flags not yet applied
+func decryptHeader(encrypted string, password, ad []byte) (header, []byte, error) {
+	h, payload, err := parseHeader(encrypted)
+	if err != nil {
+		return h, nil, err
+	}
+	switch h.algorithm {
+	case algAES256GCM, algAES192GCM, algAES128GCM, algChaCha20Poly1305:
+		plainText, err := decryptAEAD(h, payload, password, ad)
+		return h, plainText, err
+	case algStreamCTRHMAC, algStreamGCM:
+		return h, nil, fmt.Errorf("%w: vault was written by WriteStream, read it with ReadStream", ErrNotSupported)
+	case algX25519Box:
+		return h, nil, fmt.Errorf("%w: vault was written by WriteToRecipient, read it with ReadWithPrivateKey", ErrNotSupported)
+	}
+	return h, nil, fmt.Errorf("%w: id %02x", ErrUnsupportedAlgorithm, h.algorithm)
+}
+
+func decryptAEAD(h header, payload string, password, ad []byte) ([]byte, error) {
+	data, err := h.decodePayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < saltSize {
+		return nil, ErrTampered
+	}
+	salt, data := data[:saltSize], data[saltSize:]
+	aead, err := newAEAD(h, password, salt)
+	if err != nil {
+		return nil, err
+	}
+	return openAEAD(h, aead, data, ad)
+}
+
+// openAEAD opens data, the nonce and ciphertext that follow the salt
+// in an AEAD payload, with aead
+func openAEAD(h header, aead cipher.AEAD, data, ad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrTampered
+	}
+	nonce, cipherText := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plainText, err := aead.Open(nil, nonce, cipherText, h.aad(ad))
+	if err != nil {
+		return nil, ErrTampered
 	}
-	return string(b)
+	return plainText, nil
 }
 
 // Loggo is the global logger. Set this to a log15
@@ -277,6 +1826,8 @@ func log(lev, msg string, ltx ...interface{}) {
 		switch lev {
 		case "Info":
 			Loggo.Info(msg, ltx...)
+		case "Warn":
+			Loggo.Warn(msg, ltx...)
 		case "Error":
 			Loggo.Error(msg, ltx...)
 		case "Debug":
@@ -285,3 +1836,52 @@ func log(lev, msg string, ltx ...interface{}) {
 	}
 }
 
+// Secret holds a password. It formats as "[redacted]" with the fmt
+// verbs so it can't leak into logs by accident, convert it back
+// with string(s) to get at the value.
+type Secret string
+
+// String implements fmt.Stringer without revealing the secret.
+func (s Secret) String() string {
+	return "[redacted]"
+}
+
+// GoString implements fmt.GoStringer without revealing the secret.
+func (s Secret) GoString() string {
+	return `"[redacted]"`
+}
+
+// String describes the vault without revealing any password it
+// holds. The fmt package would otherwise print the unexported
+// fields, password included, verbatim.
+func (v *Vault) String() string {
+	return fmt.Sprintf("uggsec.Vault{filename: %q}", v.filename)
+}
+
+// Logger is the minimal interface a per-vault logger has to satisfy.
+// The standard library's *log.Logger works as is.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// log sends a message to the package level Loggo and, when one
+// was provided, to the vault's own Logger
+func (v *Vault) log(lev, msg string, ltx ...interface{}) {
+	logTo(v.logger, lev, msg, ltx...)
+}
+
+// logTo sends a message to the package level Loggo and to l if it
+// isn't nil, as a single line with the context pairs rendered as
+// key=value
+func logTo(l Logger, lev, msg string, ltx ...interface{}) {
+	log(lev, msg, ltx...)
+	if l == nil {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "uggsec: %s: %s", strings.ToLower(lev), msg)
+	for i := 0; i+1 < len(ltx); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", ltx[i], ltx[i+1])
+	}
+	l.Printf("%s", b.String())
+}
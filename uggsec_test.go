@@ -0,0 +1,744 @@
+package uggsec
+
+import (
+	"errors"
+	mrand "math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// testPassword is what the test vaults are encrypted with unless a
+// test says otherwise
+const testPassword = "correct horse battery staple"
+
+// newTestVault returns a password vault configured by i, stored in a
+// fresh temp dir unless i names a file. KDFParams defaults to the
+// cheapest allowed cost so tests don't spend their time in scrypt.
+func newTestVault(t testing.TB, i *VaultInput) *Vault {
+	t.Helper()
+	if i == nil {
+		i = &VaultInput{}
+	}
+	if i.Filename == "" {
+		i.Filename = filepath.Join(t.TempDir(), "vault")
+	}
+	if i.Password == "" {
+		i.Password = testPassword
+	}
+	if i.KDFParams == (KDFParams{}) {
+		i.KDFParams = KDFParams{N: minKDFN, R: 1, P: 1}
+	}
+	v, err := InitPassword(i)
+	if err != nil {
+		t.Fatalf("InitPassword: %v", err)
+	}
+	return v
+}
+
+func TestWriteIfAbsent(t *testing.T) {
+	v := newTestVault(t, nil)
+	written, err := v.WriteIfAbsent("first")
+	if err != nil || !written {
+		t.Fatalf("WriteIfAbsent() = %v, %v, want true", written, err)
+	}
+	written, err = v.WriteIfAbsent("second")
+	if err != nil || written {
+		t.Fatalf("second WriteIfAbsent() = %v, %v, want false", written, err)
+	}
+	if got, err := v.Read(); err != nil || got != "first" {
+		t.Fatalf("Read() = %q, %v", got, err)
+	}
+}
+
+func TestWriteIfAbsentRace(t *testing.T) {
+	first := newTestVault(t, nil)
+	const writers = 8
+	results := make(chan bool, writers)
+	for i := 0; i < writers; i++ {
+		v := first.Clone(first.filename)
+		go func() {
+			written, err := v.WriteIfAbsent("x")
+			if err != nil {
+				t.Error(err)
+			}
+			results <- written
+		}()
+	}
+	wins := 0
+	for i := 0; i < writers; i++ {
+		if <-results {
+			wins++
+		}
+	}
+	if wins != 1 {
+		t.Fatalf("%d writers won, want exactly 1", wins)
+	}
+}
+
+func TestWriteIfAbsentFailureLeavesNoFile(t *testing.T) {
+	for name, fsys := range map[string]*faultFS{
+		"short write": {shortWrite: true},
+		"failed link": {failLink: true},
+		"failed sync": {failSync: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVault(t, &VaultInput{Durable: true})
+			useFileSystem(t, fsys)
+			if _, err := v.WriteIfAbsent("x"); !errors.Is(err, errInjected) {
+				t.Fatalf("WriteIfAbsent() = %v, want the injected failure", err)
+			}
+			entries, err := os.ReadDir(filepath.Dir(v.filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Fatalf("%d files left behind, first %s", len(entries), entries[0].Name())
+			}
+		})
+	}
+}
+
+// fakeClock is a clock for VaultInput.Clock that only moves when a
+// test advances it
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// tamperAt changes the base64 character at b[i] to another valid one
+// differing in its high bits, so the change reaches authentication
+// rather than failing to decode or landing in padding bits
+func tamperAt(b []byte, i int) {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+	k := strings.IndexByte(alphabet, b[i])
+	if k < 0 {
+		panic("tamperAt: not a base64 character")
+	}
+	b[i] = alphabet[k^32]
+}
+
+func TestRotate(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("kept across rotation"); err != nil {
+		t.Fatal(err)
+	}
+	newPassword := NewVaultPassword()
+	if err := v.Rotate(newPassword); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.Read(); err != nil || got != "kept across rotation" {
+		t.Fatalf("Read() after Rotate = %q, %v", got, err)
+	}
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decrypt(string(data), []byte(testPassword), nil); !errors.Is(err, ErrTampered) {
+		t.Fatalf("old password still decrypts: %v", err)
+	}
+	if _, err := decrypt(string(data), []byte(newPassword), nil); err != nil {
+		t.Fatalf("new password doesn't decrypt: %v", err)
+	}
+}
+
+func TestRotateMemoryFailedSetKeepsContents(t *testing.T) {
+	v, err := InitMemory(&VaultInput{KDFParams: KDFParams{N: minKDFN, R: 1, P: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Write("in memory"); err != nil {
+		t.Fatal(err)
+	}
+	// keep the password in a keyring whose Set fails
+	v.keyring, v.service, v.user = true, "svc", "usr"
+	v.ring = failingRing{mapRing: mapRing{"svc/usr": string(v.password)}, failUser: "usr"}
+	if err := v.Rotate(""); !errors.Is(err, errInjected) {
+		t.Fatalf("Rotate() = %v, want the injected failure", err)
+	}
+	if got, err := v.Read(); err != nil || got != "in memory" {
+		t.Fatalf("Read() after failed Rotate = %q, %v", got, err)
+	}
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	v := newTestVault(t, nil)
+	for _, contents := range []string{"", "hello", strings.Repeat("multi\nline ", 1000)} {
+		if err := v.Write(contents); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := v.Read(); err != nil || got != contents {
+			t.Fatalf("Read() = %q, %v, want %q", got, err, contents)
+		}
+	}
+}
+
+func TestWriteUsesFreshNonce(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("same plaintext"); err != nil {
+		t.Fatal(err)
+	}
+	first, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Write("same plaintext"); err != nil {
+		t.Fatal(err)
+	}
+	second, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) == string(second) {
+		t.Fatal("two writes of the same plaintext produced the same ciphertext")
+	}
+}
+
+func TestReadTampered(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("do not touch"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tamperAt(data, len(data)-5)
+	if err := os.WriteFile(v.filename, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Read(); !errors.Is(err, ErrTampered) {
+		t.Fatalf("Read() = %v, want ErrTampered", err)
+	}
+}
+
+func TestReadWrongPassword(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("secret"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := InitPassword(&VaultInput{Filename: v.filename, Password: "a different passphrase"})
+	if !errors.Is(err, ErrTampered) {
+		t.Fatalf("InitPassword() with the wrong password = %v, want ErrTampered", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ring := mapRing{}
+	v := newKeyringTestVault(t, ring)
+	if err := v.Write("x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(v.filename); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("vault file still there: %v", err)
+	}
+	if len(ring) != 0 {
+		t.Fatalf("keyring still holds %v", ring)
+	}
+	if err := v.Delete(); err != nil {
+		t.Fatalf("second Delete() = %v, want nothing left to fail", err)
+	}
+}
+
+func TestDeleteAttemptsBothSteps(t *testing.T) {
+	ring := mapRing{"svc/usr": NewVaultPassword()}
+	v := newKeyringTestVault(t, failingRing{mapRing: ring, failUser: "usr", failDelete: true})
+	if err := v.Write("x"); err != nil {
+		t.Fatal(err)
+	}
+	err := v.Delete()
+	if err == nil || !strings.Contains(err.Error(), "deleting keyring secret") {
+		t.Fatalf("Delete() = %v, want the keyring failure reported", err)
+	}
+	if _, err := os.Stat(v.filename); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("vault file kept after the keyring failed: %v", err)
+	}
+}
+
+func TestRotateKeyring(t *testing.T) {
+	ring := mapRing{}
+	v := newKeyringTestVault(t, ring)
+	if err := v.Write("kept across rotation"); err != nil {
+		t.Fatal(err)
+	}
+	oldPassword := ring["svc/usr"]
+	if err := v.Rotate(""); err != nil {
+		t.Fatal(err)
+	}
+	newPassword := ring["svc/usr"]
+	if newPassword == "" || newPassword == oldPassword {
+		t.Fatal("Rotate() didn't store a new password in the keyring")
+	}
+	data, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decrypt(string(data), []byte(oldPassword), nil); !errors.Is(err, ErrTampered) {
+		t.Fatalf("old password still decrypts: %v", err)
+	}
+	if got, err := v.Read(); err != nil || got != "kept across rotation" {
+		t.Fatalf("Read() after Rotate = %q, %v", got, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(v.filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Rotate() left %d files behind", len(entries)-1)
+	}
+}
+
+func TestRotateFailedRenameKeepsOldPassword(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("x"); err != nil {
+		t.Fatal(err)
+	}
+	useFileSystem(t, &faultFS{failRename: true})
+	if err := v.Rotate(NewVaultPassword()); !errors.Is(err, errInjected) {
+		t.Fatalf("Rotate() = %v, want the injected failure", err)
+	}
+	if got, err := v.Read(); err != nil || got != "x" {
+		t.Fatalf("Read() after failed Rotate = %q, %v", got, err)
+	}
+}
+
+// crossDeviceFS fails every rename the way renaming across file
+// systems does
+type crossDeviceFS struct{ osFileSystem }
+
+func (crossDeviceFS) Rename(oldName, newName string) error {
+	return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: syscall.EXDEV}
+}
+
+func TestWriteFallsBackToCopyAcrossDevices(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("old"); err != nil {
+		t.Fatal(err)
+	}
+	useFileSystem(t, crossDeviceFS{})
+	if err := v.Write("new"); err != nil {
+		t.Fatalf("Write() = %v, want the copy fallback to succeed", err)
+	}
+	if got, err := v.Read(); err != nil || got != "new" {
+		t.Fatalf("Read() = %q, %v", got, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(v.filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("fallback left %d temp files behind", len(entries)-1)
+	}
+}
+
+func TestWriteFileMode(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("x"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Fatalf("vault file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestReadGarbage(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("x"); err != nil {
+		t.Fatal(err)
+	}
+	good, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, garbage := range [][]byte{
+		[]byte("\x00\x01\xffgarbage"),
+		[]byte("just some text file"),
+		append(append([]byte(nil), good[:12]...), "!!not base64!!"...),
+		good[:len(good)/2],
+		good[:8],
+	} {
+		if err := os.WriteFile(v.filename, garbage, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.Read(); err == nil {
+			t.Errorf("Read() of %q succeeded", garbage)
+		}
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	v := newTestVault(t, nil)
+	const writers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < 5; n++ {
+				if err := v.Write(strings.Repeat(string(rune('a'+i)), 100)); err != nil {
+					t.Error(err)
+				}
+				if _, err := v.Read(); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	got, err := v.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 100 || strings.Count(got, got[:1]) != 100 {
+		t.Fatalf("Read() = %q, want one writer's contents", got)
+	}
+}
+
+func TestInitEnvVarPasswordChecks(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "vault")
+	t.Setenv("UGGSEC_TEST_PASSWORD", "")
+	_, err := InitEnvVar(&VaultInput{Filename: filename, PasswordEnvVar: "UGGSEC_TEST_PASSWORD"})
+	if !errors.Is(err, ErrMissingPassword) {
+		t.Fatalf("InitEnvVar() with the var unset = %v, want ErrMissingPassword", err)
+	}
+	t.Setenv("UGGSEC_TEST_PASSWORD", "0123456789")
+	_, err = InitEnvVar(&VaultInput{Filename: filename, PasswordEnvVar: "UGGSEC_TEST_PASSWORD"})
+	if !errors.Is(err, ErrWeakPassword) || !strings.Contains(err.Error(), "10 characters") {
+		t.Fatalf("InitEnvVar() with a 10 byte password = %v, want ErrWeakPassword giving its length", err)
+	}
+	t.Setenv("UGGSEC_TEST_PASSWORD", NewVaultPassword())
+	v, err := InitEnvVar(&VaultInput{Filename: filename, PasswordEnvVar: "UGGSEC_TEST_PASSWORD", KDFParams: KDFParams{N: minKDFN, R: 1, P: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Write("x"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInitFS(t *testing.T) {
+	sealer := newTestVault(t, nil)
+	ciphertext, err := sealer.WriteDryRun("baked into the binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"config/secrets.vault": {Data: []byte(ciphertext)}}
+	v, err := InitFS(fsys, &VaultInput{Filename: "config/secrets.vault", Password: testPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := v.Read(); err != nil || got != "baked into the binary" {
+		t.Fatalf("Read() = %q, %v", got, err)
+	}
+	if err := v.Write("x"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Write() = %v, want ErrReadOnly", err)
+	}
+	if err := v.Delete(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Delete() = %v, want ErrReadOnly", err)
+	}
+	if _, err := InitFS(fsys, &VaultInput{Filename: "config/missing.vault", Password: testPassword}); !errors.Is(err, ErrVaultNotFound) {
+		t.Fatalf("InitFS() of a missing file = %v, want ErrVaultNotFound", err)
+	}
+	if _, err := InitFS(fsys, &VaultInput{Filename: "/config/secrets.vault", Password: testPassword}); err == nil {
+		t.Fatal("InitFS() accepted a rooted path")
+	}
+}
+
+func TestWriteDryRun(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("on disk"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := v.WriteDryRun("previewed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.ReadFile(v.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Fatal("WriteDryRun() changed the vault file")
+	}
+	got, err := decrypt(ciphertext, []byte(testPassword), nil)
+	if err != nil || string(got) != "previewed" {
+		t.Fatalf("decrypting the dry run = %q, %v", got, err)
+	}
+}
+
+func TestWriteDryRunMatchesWrite(t *testing.T) {
+	// two vaults drawing the same random bytes seal identically, so
+	// the dry run has to be exactly what Write stores
+	dir := t.TempDir()
+	preview := newTestVault(t, &VaultInput{Filename: filepath.Join(dir, "a"), InsecureRandSource: mrand.New(mrand.NewSource(7))})
+	written := newTestVault(t, &VaultInput{Filename: filepath.Join(dir, "b"), InsecureRandSource: mrand.New(mrand.NewSource(7))})
+	ciphertext, err := preview.WriteDryRun("same path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := written.Write("same path"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(written.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != ciphertext {
+		t.Fatal("WriteDryRun() and Write() sealed the same contents differently")
+	}
+}
+
+func TestReadOnlyBlocksMutations(t *testing.T) {
+	w := newTestVault(t, nil)
+	if err := w.WriteMap(map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(w.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newTestVault(t, &VaultInput{Filename: w.filename, ReadOnly: true})
+	if _, err := v.Get("k"); err != nil {
+		t.Fatalf("reading a read-only vault: %v", err)
+	}
+	var recipient [32]byte
+	for name, mutate := range map[string]func() error{
+		"Write":        func() error { return v.Write("x") },
+		"WriteBytes":   func() error { return v.WriteBytes([]byte("x")) },
+		"WriteWithTTL": func() error { return v.WriteWithTTL("x", time.Hour) },
+		"WriteWithAAD": func() error { return v.WriteWithAAD("x", []byte("ad")) },
+		"WriteJSON":    func() error { return v.WriteJSON(1) },
+		"WriteLines":   func() error { return v.WriteLines([]string{"x"}) },
+		"WriteMap":     func() error { return v.WriteMap(nil) },
+		"WriteEntry":   func() error { return v.WriteEntry("k", "x") },
+		"WriteFile":    func() error { return v.WriteFile(w.filename, "x") },
+		"WriteStream":  func() error { return v.WriteStream(strings.NewReader("x")) },
+		"WriteToRecipient": func() error {
+			return v.WriteToRecipient([]byte("x"), &recipient)
+		},
+		"WriteIfAbsent": func() error { _, err := v.WriteIfAbsent("x"); return err },
+		"WriteDryRun":   func() error { _, err := v.WriteDryRun("x"); return err },
+		"Set":           func() error { return v.Set("k", "x") },
+		"DeleteEntry":   func() error { return v.DeleteEntry("k") },
+		"Append":        func() error { return v.Append("x") },
+		"AppendRecord":  func() error { return v.AppendRecord(1) },
+		"Compact":       func() error { return v.Compact() },
+		"Migrate":       func() error { return v.Migrate() },
+		"Rotate":        func() error { return v.Rotate(NewVaultPassword()) },
+		"Delete":        func() error { return v.Delete() },
+		"CopyTo":        func() error { return w.CopyTo(v) },
+	} {
+		if err := mutate(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s() = %v, want ErrReadOnly", name, err)
+		}
+	}
+	after, err := os.ReadFile(w.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Fatal("a read-only vault changed its file")
+	}
+}
+
+func TestSize(t *testing.T) {
+	checkSize := func(t *testing.T, v *Vault, want int) {
+		t.Helper()
+		fi, err := os.Stat(v.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, cipher, err := v.Size()
+		if err != nil || plain != want || cipher != int(fi.Size()) {
+			t.Fatalf("Size() = %d, %d, %v, want %d, %d", plain, cipher, err, want, fi.Size())
+		}
+	}
+	v := newTestVault(t, nil)
+	if _, _, err := v.Size(); !errors.Is(err, ErrVaultNotFound) {
+		t.Fatalf("Size() without a file = %v, want ErrVaultNotFound", err)
+	}
+	if err := v.Write("hello"); err != nil {
+		t.Fatal(err)
+	}
+	checkSize(t, v, len("hello"))
+	payload := strings.Repeat("x", 3*streamChunkSize+1)
+	if err := v.WriteStream(strings.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+	checkSize(t, v, len(payload))
+	legacy, err := InitPassword(&VaultInput{Filename: copyLegacyFixture(t), Password: legacyPassword, AllowLegacy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSize(t, legacy, len("hello from an old release"))
+}
+
+func TestSealOpen(t *testing.T) {
+	sealed, err := Seal([]byte("no vault needed"), testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Open(sealed, testPassword); err != nil || string(got) != "no vault needed" {
+		t.Fatalf("Open() = %q, %v", got, err)
+	}
+	if _, err := Open(sealed, "some other password entirely"); !errors.Is(err, ErrTampered) {
+		t.Fatalf("Open() with the wrong password = %v, want ErrTampered", err)
+	}
+	b := []byte(sealed)
+	tamperAt(b, len(b)-5)
+	if _, err := Open(string(b), testPassword); !errors.Is(err, ErrTampered) {
+		t.Fatalf("Open() of tampered ciphertext = %v, want ErrTampered", err)
+	}
+	if _, err := Seal([]byte("x"), ""); !errors.Is(err, ErrMissingPassword) {
+		t.Fatalf("Seal() without a password = %v, want ErrMissingPassword", err)
+	}
+	if _, err := Open(sealed, ""); !errors.Is(err, ErrMissingPassword) {
+		t.Fatalf("Open() without a password = %v, want ErrMissingPassword", err)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	v := newTestVault(t, nil)
+	ciphertext, err := v.Encrypt("for the database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(v.filename); !os.IsNotExist(err) {
+		t.Fatalf("Encrypt() touched the vault's file: %v", err)
+	}
+	if got, err := v.Decrypt(ciphertext); err != nil || got != "for the database" {
+		t.Fatalf("Decrypt() = %q, %v", got, err)
+	}
+	// Open reads what a vault without AssociatedData encrypted
+	if got, err := Open(ciphertext, testPassword); err != nil || string(got) != "for the database" {
+		t.Fatalf("Open() of Encrypt output = %q, %v", got, err)
+	}
+	b := []byte(ciphertext)
+	tamperAt(b, len(b)-5)
+	if _, err := v.Decrypt(string(b)); !errors.Is(err, ErrTampered) {
+		t.Fatalf("Decrypt() of tampered ciphertext = %v, want ErrTampered", err)
+	}
+}
+
+func TestExistsIsEmpty(t *testing.T) {
+	v := newTestVault(t, nil)
+	other := newTestVault(t, &VaultInput{Filename: v.filename, Password: "some other password entirely"})
+	check := func(step string, wantExists, wantEmpty bool) {
+		t.Helper()
+		exists, err := v.Exists()
+		if err != nil || exists != wantExists {
+			t.Fatalf("%s: Exists() = %t, %v, want %t", step, exists, err, wantExists)
+		}
+		empty, err := v.IsEmpty()
+		if err != nil || empty != wantEmpty {
+			t.Fatalf("%s: IsEmpty() = %t, %v, want %t", step, empty, err, wantEmpty)
+		}
+	}
+	check("no file", false, true)
+	if err := v.Init(); err != nil {
+		t.Fatal(err)
+	}
+	check("after Init", true, true)
+	if err := v.Write("something"); err != nil {
+		t.Fatal(err)
+	}
+	check("after Write", true, false)
+	if err := v.Write(""); err != nil {
+		t.Fatal(err)
+	}
+	check("after writing nothing", true, true)
+	if err := v.Write("something"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.IsEmpty(); !errors.Is(err, ErrTampered) {
+		t.Fatalf("IsEmpty() with the wrong password = %v, want ErrTampered", err)
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	v := newTestVault(t, nil)
+	other := newTestVault(t, &VaultInput{Filename: v.filename, Password: "some other password entirely"})
+	if ok, err := other.VerifyPassword(); err != nil || !ok {
+		t.Fatalf("VerifyPassword() with nothing stored = %t, %v, want true", ok, err)
+	}
+	if err := v.Write("something"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := v.VerifyPassword(); err != nil || !ok {
+		t.Fatalf("VerifyPassword() = %t, %v, want true", ok, err)
+	}
+	if ok, err := other.VerifyPassword(); err != nil || ok {
+		t.Fatalf("VerifyPassword() with the wrong password = %t, %v, want false", ok, err)
+	}
+	t.Setenv("UGGSEC_VERIFY_TEST", testPassword)
+	env, err := InitEnvVar(&VaultInput{Filename: v.filename, PasswordEnvVar: "UGGSEC_VERIFY_TEST"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Unsetenv("UGGSEC_VERIFY_TEST")
+	if _, err := env.VerifyPassword(); !errors.Is(err, ErrMissingPassword) {
+		t.Fatalf("VerifyPassword() without a password = %v, want ErrMissingPassword", err)
+	}
+}
+
+func TestInitObfuscate(t *testing.T) {
+	logger := &recordLogger{}
+	filename := filepath.Join(t.TempDir(), "vault")
+	v, err := InitObfuscate(&VaultInput{Filename: filename, Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := v.Mechanism(); m != MechanismObfuscate {
+		t.Fatalf("Mechanism() = %s, want %s", m, MechanismObfuscate)
+	}
+	if len(logger.lines) == 0 {
+		t.Fatal("InitObfuscate() didn't log a warning")
+	}
+	if err := v.Write("merely hidden"); err != nil {
+		t.Fatal(err)
+	}
+	// the key is built in, so any obfuscated vault reads the file
+	again, err := InitObfuscate(&VaultInput{Filename: filename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := again.Read(); err != nil || got != "merely hidden" {
+		t.Fatalf("Read() = %q, %v", got, err)
+	}
+	if err := v.Rotate(""); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Rotate() = %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,159 @@
+package uggsec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ValidationError is returned by Validate and lists every problem
+// found with a VaultInput rather than stopping at the first one.
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return "invalid vault configuration: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the problems matches target, so callers
+// can still check for sentinels such as ErrMissingPassword with
+// errors.Is.
+func (e *ValidationError) Is(target error) bool {
+	for _, p := range e.Problems {
+		if errors.Is(p, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that i describes a usable vault without creating
+// keyring secrets or writing any files, which makes it suitable for
+// something like a CLI's --check flag. It picks the password
+// mechanism the same way InitSmart does, short of trying the
+// keyring, and checks that mechanism's settings, that the settings
+// for different mechanisms don't conflict, and that the vault's
+// file can be written. It returns nil or a *ValidationError
+// describing every problem found.
+func Validate(i *VaultInput) error {
+	var problems []error
+	add := func(err error) {
+		problems = append(problems, err)
+	}
+	keyringSet := i.Service != "" || i.User != ""
+	useKeyring := !i.DisableKeyring && i.Service != "" && i.User != ""
+	switch {
+	case i.Password != "":
+		if i.PasswordEnvVar != "" {
+			add(fmt.Errorf("both Password and PasswordEnvVar are set, only Password would be used"))
+		}
+		if keyringSet {
+			add(fmt.Errorf("both Password and keyring Service/User are set, only Password would be used"))
+		}
+		if i.PasswordFile != "" {
+			add(fmt.Errorf("both Password and PasswordFile are set, only Password would be used"))
+		}
+	case i.PasswordFile != "":
+		if i.PasswordEnvVar != "" {
+			add(fmt.Errorf("both PasswordFile and PasswordEnvVar are set, only PasswordFile would be used"))
+		}
+		if keyringSet {
+			add(fmt.Errorf("both PasswordFile and keyring Service/User are set, only PasswordFile would be used"))
+		}
+		if password, err := filePassword(i.PasswordFile); err != nil {
+			add(err)
+		} else {
+			wipe(password)
+		}
+	case useKeyring:
+		// PasswordEnvVar, if set, is only the fallback here and it
+		// isn't known yet whether it will be needed
+	case i.PasswordEnvVar != "":
+		if keyringSet {
+			add(fmt.Errorf("keyring Service/User are set but won't be used, only PasswordEnvVar would be"))
+		}
+		// an unknown PasswordEnvVarEncoding is reported below
+		v := &Vault{passwordEnvVar: i.PasswordEnvVar, envEncoding: i.PasswordEnvVarEncoding}
+		if _, err := v.getPasswordEnv(); err != nil && checkPasswordEncoding(v.envEncoding) == nil {
+			add(err)
+		}
+	case i.DisableKeyring:
+		add(fmt.Errorf("%w: PasswordEnvVar, PasswordFile or Password must be set when DisableKeyring is", ErrMissingPassword))
+	default:
+		if i.Service == "" || i.User == "" {
+			add(ErrMissingKeyringLabel)
+		}
+	}
+	if i.SecondaryPasswordEnvVar != "" {
+		if _, err := envPassword(i.SecondaryPasswordEnvVar, PasswordEncodingRaw); err != nil {
+			add(err)
+		}
+	}
+	if err := i.KDFParams.check(); err != nil {
+		add(err)
+	}
+	if _, err := contentAlgorithm(i.Algorithm, i.KeySize); err != nil {
+		add(err)
+	}
+	if i.ReadOnly && i.CreateIfMissing {
+		add(fmt.Errorf("both ReadOnly and CreateIfMissing are set, a read-only vault can't create its file"))
+	}
+	if i.AllowLegacy && len(i.AssociatedData) > 0 {
+		add(fmt.Errorf("both AllowLegacy and AssociatedData are set, legacy vaults can't be bound to AssociatedData so they won't be read"))
+	}
+	if err := checkEncoding(i.Encoding, i.RawBinary); err != nil {
+		add(err)
+	}
+	if err := checkPasswordEncoding(i.PasswordEnvVarEncoding); err != nil {
+		add(err)
+	}
+	if err := validateFilename(i.Filename); err != nil {
+		add(err)
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateFilename checks that filename names a file that could be
+// written without actually writing anything
+func validateFilename(filename string) error {
+	if filename == "" {
+		return errors.New("Filename is not set")
+	}
+	info, err := os.Stat(filename)
+	if err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("Filename %s is a directory", filename)
+		}
+		// opening for write without O_TRUNC leaves the file as is
+		f, err := os.OpenFile(filename, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("vault file is not writable: %w", err)
+		}
+		return f.Close()
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	dir := filepath.Dir(filename)
+	info, err = os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("vault directory is not usable: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("vault directory %s is not a directory", dir)
+	}
+	if info.Mode().Perm()&0222 == 0 {
+		return fmt.Errorf("vault directory %s is not writable", dir)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package uggsec
+
+import (
+	"context"
+	"github.com/fsnotify/fsnotify"
+	"path/filepath"
+)
+
+// Watch notifies the caller whenever the vault's file is changed on
+// disk, for example by another process calling Write or Rotate, so
+// long running programs know to Read it again. The directory holding
+// the file is watched rather than the file itself so that the atomic
+// rename every write ends with is seen, and the watch keeps working
+// across replacements.
+//
+// Notifications are coalesced: the returned channel has room for one
+// pending notification and further changes are dropped until the
+// caller receives it. The channel is closed when ctx is cancelled or
+// the watcher fails. In-memory vaults have no file to watch and
+// return ErrNotSupported.
+func (v *Vault) Watch(ctx context.Context) (changed <-chan struct{}, err error) {
+	defer wrapOp("watch", v.filename, &err)
+	if !v.usesOSFiles() {
+		return nil, ErrNotSupported
+	}
+	filename, err := filepath.Abs(v.filename)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	v.log("Debug", "Watch(), watching vault directory...", "dir", filepath.Dir(filename))
+	err = watcher.Add(filepath.Dir(filename))
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filename || event.Op == fsnotify.Chmod {
+					continue
+				}
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				v.log("Warn", "Watch(), watcher failed", "error", err.Error())
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
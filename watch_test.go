@@ -0,0 +1,68 @@
+package uggsec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitForChange fails the test unless changed delivers within a few
+// seconds
+func waitForChange(t *testing.T, changed <-chan struct{}) {
+	t.Helper()
+	select {
+	case _, ok := <-changed:
+		if !ok {
+			t.Fatal("Watch() channel closed before the change")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch() didn't report the change")
+	}
+}
+
+func TestWatchSeesExternalWrite(t *testing.T) {
+	v := newTestVault(t, nil)
+	if err := v.Write("before"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed, err := v.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// another file in the same directory isn't the vault's change
+	if err := os.WriteFile(filepath.Join(filepath.Dir(v.filename), "unrelated"), []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+		t.Fatal("Watch() reported a change to another file")
+	case <-time.After(100 * time.Millisecond):
+	}
+	// a second vault on the same file stands in for another process
+	other := newTestVault(t, &VaultInput{Filename: v.filename})
+	if err := other.Write("after"); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, changed)
+	if got, err := v.Read(); err != nil || got != "after" {
+		t.Fatalf("Read() after the change = %q, %v", got, err)
+	}
+	cancel()
+	for range changed {
+	}
+}
+
+func TestWatchNotSupportedInMemory(t *testing.T) {
+	v, err := InitMemory(&VaultInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Watch(context.Background()); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Watch() = %v, want ErrNotSupported", err)
+	}
+}